@@ -0,0 +1,89 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "os"
+    "strings"
+
+    "heimdal/internal/git"
+    "heimdal/internal/universe"
+
+    "golang.org/x/term"
+)
+
+// checkpoint commits the working tree of the git repo around dir to the
+// session's checkpoint ref, so `session rollback` can undo the run. It
+// returns the commit and the repo's root.
+func checkpoint(sessionID, dir, app string) (commit, root string, err error) {
+    repo, err := git.Open(dir)
+    if err != nil { return "", "", err }
+    commit, err = repo.Checkpoint(git.CheckpointRef(sessionID), "heimdal: before "+app+" in session "+sessionID)
+    return commit, repo.Root, err
+}
+
+// sessionRollback restores the working tree to the checkpoint taken before
+// the session's first run. The tree it replaces is saved under the undo ref
+// first, so a rollback can itself be rolled back with git.
+func sessionRollback(cwd string, args []string) error {
+    const usage = "usage: heimdal session rollback [--yes] <id>"
+    var id string
+    yes := false
+    for _, a := range args {
+        switch {
+        case a == "--yes" || a == "-y":
+            yes = true
+        case id == "" && !strings.HasPrefix(a, "-"):
+            id = a
+        default:
+            return errors.New(usage)
+        }
+    }
+    if id == "" { return errors.New(usage) }
+    sess, err := universe.OpenSession(cwd, id)
+    if err != nil { return err }
+    m, err := sess.LoadMeta()
+    if err != nil { return err }
+    if m.Checkpoint == "" { return fmt.Errorf("session %s has no git checkpoint", sess.ID) }
+    root := m.CheckpointRepo
+    if root == "" { root = m.Workdir } // sessions from before CheckpointRepo
+    repo, err := git.Open(root)
+    if err != nil { return fmt.Errorf("%s: %w", root, err) }
+    target, err := repo.Resolve(git.CheckpointRef(sess.ID))
+    if err != nil { return err }
+
+    current, err := repo.WriteTree()
+    if err != nil { return err }
+    changes, err := repo.Diff(target, current)
+    if err != nil { return err }
+    if len(changes) == 0 {
+        fmt.Printf("%s already matches checkpoint %s\n", repo.Root, git.Short(target))
+        return nil
+    }
+    for _, c := range changes {
+        verb := "restore"
+        if c.Status == 'A' { verb = "delete" }
+        fmt.Printf("  %-7s %s\n", verb, c.Path)
+    }
+    if !yes {
+        if !term.IsTerminal(int(os.Stdin.Fd())) { return errors.New("not a terminal; pass --yes to roll back") }
+        fmt.Printf("Roll back %d file(s) in %s to checkpoint %s? [y/N] ", len(changes), repo.Root, git.Short(target))
+        line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+        if a := strings.ToLower(strings.TrimSpace(line)); a != "y" && a != "yes" {
+            fmt.Println("aborted")
+            return nil
+        }
+    }
+
+    undo, err := repo.Commit(git.UndoRef(sess.ID), current, "heimdal: before rollback of session "+sess.ID)
+    if err != nil { return err }
+    if err := repo.Restore(target, changes); err != nil { return err }
+    fmt.Printf("rolled back %d file(s) to checkpoint %s; the replaced tree is saved as %s (%s)\n",
+        len(changes), git.Short(target), git.UndoRef(sess.ID), git.Short(undo))
+    // Commits made during the session are left alone; point them out.
+    if parent, err := repo.Resolve(target + "^"); err == nil && parent != repo.Head() {
+        fmt.Printf("note: HEAD is no longer at %s, where the checkpoint was taken; commits made since are kept\n", git.Short(parent))
+    }
+    return nil
+}
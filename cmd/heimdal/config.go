@@ -0,0 +1,78 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "text/tabwriter"
+
+    "heimdal/internal/config"
+)
+
+const usageConfig = "usage: heimdal config [show [--origin]|get <key> [--origin]|set [--project] <key> <value>]"
+
+func cmdConfig(cfg *config.Config, args []string) error {
+    if len(args) == 0 { return errors.New(usageConfig) }
+    sub := args[0]
+    origin := false
+    project := false
+    var rest []string
+    for _, a := range args[1:] {
+        switch a {
+        case "--origin":
+            origin = true
+        case "--project":
+            project = true
+        default:
+            rest = append(rest, a)
+        }
+    }
+    switch sub {
+    case "show":
+        if len(rest) != 0 { return errors.New(usageConfig) }
+        tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        for _, v := range cfg.All() {
+            if origin {
+                fmt.Fprintf(tw, "%s\t%q\t%s\n", v.Key, v.Value, describeOrigin(v))
+            } else {
+                fmt.Fprintf(tw, "%s\t%q\n", v.Key, v.Value)
+            }
+        }
+        return tw.Flush()
+    case "get":
+        if len(rest) != 1 { return errors.New("usage: heimdal config get <key> [--origin]") }
+        v, ok := cfg.Get(rest[0])
+        if !ok { return fmt.Errorf("unknown setting %q", rest[0]) }
+        if origin {
+            fmt.Printf("%s\t(%s)\n", v.Value, describeOrigin(v))
+            return nil
+        }
+        fmt.Println(v.Value)
+        return nil
+    case "set":
+        if len(rest) != 2 { return errors.New("usage: heimdal config set [--project] <key> <value>") }
+        path := cfg.UserPath
+        if project {
+            path = cfg.ProjectPath
+            if path == "" {
+                cwd, _ := os.Getwd()
+                path = filepath.Join(cwd, config.ProjectFile)
+            }
+        }
+        if path == "" { return errors.New("cannot determine config file (no home directory)") }
+        if project {
+            if err := cfg.CheckProject(rest[0], rest[1]); err != nil { return fmt.Errorf("%s: %w", rest[0], err) }
+        }
+        if err := config.SetInFile(path, rest[0], rest[1]); err != nil { return err }
+        fmt.Printf("set %s in %s\n", rest[0], path)
+        return nil
+    default:
+        return errors.New(usageConfig)
+    }
+}
+
+func describeOrigin(v config.Value) string {
+    if v.Source == "" { return v.Layer }
+    return v.Layer + ": " + v.Source
+}
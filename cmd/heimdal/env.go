@@ -0,0 +1,92 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "heimdal/internal/audit"
+    "heimdal/internal/manifest"
+    "heimdal/internal/secret"
+    "heimdal/internal/universe"
+)
+
+// minimalEnv is what the restricted profile passes through from the host,
+// besides the manifest's env_passthrough. HEIMDAL_* keeps settings such as
+// HEIMDAL_PROFILE for heimdal commands the app runs.
+var minimalEnv = []string{"PATH", "HOME", "TERM", "HEIMDAL", "HEIMDAL_*"}
+
+// hostEnv returns the host env vars the app inherits: everything under
+// permissive, only minimalEnv and env_passthrough under restricted, and
+// never env_deny or the secret store's unlock vars.
+func hostEnv(environ []string, m manifest.Manifest, profile string) map[string]string {
+    env := map[string]string{}
+    for _, kv := range environ {
+        i := strings.IndexByte(kv, '=')
+        if i <= 0 { continue }
+        k := kv[:i]
+        if manifest.MatchEnv(secret.UnlockEnv, k) { continue }
+        if profile == "restricted" && !manifest.MatchEnv(minimalEnv, k) && !manifest.MatchEnv(m.EnvPassthrough, k) { continue }
+        if manifest.MatchEnv(m.EnvDeny, k) { continue }
+        env[k] = kv[i+1:]
+    }
+    return env
+}
+
+// universeEnv returns the HEIMDAL_* vars describing a session.
+func universeEnv(sessionID, contextDir, workdir string) map[string]string {
+    return map[string]string{
+        "HEIMDAL":             "1",
+        "HEIMDAL_UNIVERSE":    "1",
+        "HEIMDAL_SESSION":     sessionID,
+        "HEIMDAL_CONTEXT_DIR": contextDir,
+        "HEIMDAL_WORKDIR":     workdir,
+    }
+}
+
+// appEnv layers the filtered host env, the universe vars and the manifest's
+// env, whose values are passed through value (in key order). injected holds
+// the vars heimdal set itself.
+func appEnv(m manifest.Manifest, profile string, uni map[string]string, value func(k, v string) (string, error)) (env, injected map[string]string, err error) {
+    env = hostEnv(os.Environ(), m, profile)
+    injected = map[string]string{}
+    for k, v := range uni {
+        env[k] = v
+        injected[k] = v
+    }
+    for _, k := range audit.SortedKeys(m.Env) {
+        v, err := value(k, m.Env[k])
+        if err != nil { return nil, nil, err }
+        env[k] = v
+        injected[k] = v
+    }
+    return env, injected, nil
+}
+
+// printEnv prints the environment `heimdal run` would give app, without
+// starting a session. secret:// refs are shown unresolved and values that
+// look like credentials are masked.
+func printEnv(app string, opts runOptions) error {
+    m, _, err := findManifest(app)
+    if err != nil { return err }
+    if err := checkProfile(opts.profile); err != nil { return err }
+    cwd, _ := os.Getwd()
+    sessDir := filepath.Join(universe.SessionsDir(cwd), "<session>")
+    uni := universeEnv("<session>", filepath.Join(sessDir, "context"), cwd)
+    if m.Context.MCP { uni["HEIMDAL_MCP_CONFIG"] = filepath.Join(sessDir, "context", mcpConfigFile) }
+    red := opts.red
+    red.AddEnv(os.Environ())
+    env, _, err := appEnv(m, opts.profile, uni, func(k, v string) (string, error) {
+        if _, ok := secret.ParseRef(v); ok { return v, nil }
+        v = os.ExpandEnv(v)
+        red.AddSensitive(k, v)
+        return v, nil
+    })
+    if err != nil { return err }
+    fmt.Printf("# app=%s profile=%s\n", app, opts.profile)
+    for _, k := range audit.SortedKeys(env) {
+        fmt.Printf("%s=%s\n", k, red.String(env[k]))
+    }
+    return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "heimdal/internal/manifest"
+    "heimdal/internal/secret"
+)
+
+func TestHostEnvDropsPassphrase(t *testing.T) {
+    environ := []string{"PATH=/usr/bin", "HOME=/home/me", "LANG=C.UTF-8", secret.PassphraseEnv + "=hunter2-hunter2"}
+    manifests := []manifest.Manifest{
+        {},
+        {EnvPassthrough: []string{secret.PassphraseEnv}},
+        {EnvPassthrough: []string{"HEIMDAL_*"}},
+        {EnvPassthrough: []string{"*"}},
+    }
+    for _, profile := range []string{"permissive", "restricted"} {
+        for _, m := range manifests {
+            env := hostEnv(environ, m, profile)
+            if _, ok := env[secret.PassphraseEnv]; ok { t.Errorf("%s with env_passthrough %q: app inherits %s", profile, m.EnvPassthrough, secret.PassphraseEnv) }
+            if env["PATH"] != "/usr/bin" { t.Errorf("%s with env_passthrough %q: PATH = %q", profile, m.EnvPassthrough, env["PATH"]) }
+        }
+    }
+    if env := hostEnv(environ, manifest.Manifest{}, "permissive"); env["LANG"] != "C.UTF-8" { t.Errorf("permissive dropped LANG: %v", env) }
+}
+
+func TestPrintEnv(t *testing.T) {
+    dir := t.TempDir()
+    wd, err := os.Getwd()
+    if err != nil { t.Fatal(err) }
+    if err := os.Chdir(dir); err != nil { t.Fatal(err) }
+    defer os.Chdir(wd)
+    if err := os.MkdirAll(filepath.Join(dir, "apps"), 0o755); err != nil { t.Fatal(err) }
+    m := "name: demo\ncmd: demo\nenv:\n  DEMO_MODE: fast\n  DEMO_TOKEN: secret://demo-token\nenv_passthrough: [LANG]\nenv_deny: [HEIMDAL_PROMPT_PREFIX]\n"
+    if err := os.WriteFile(filepath.Join(dir, "apps", "demo.yaml"), []byte(m), 0o644); err != nil { t.Fatal(err) }
+    t.Setenv("LANG", "C.UTF-8")
+    t.Setenv("EDITOR", "vi")
+    t.Setenv("HEIMDAL_PROFILE", "restricted")
+    t.Setenv("HEIMDAL_PROMPT_PREFIX", "> ")
+    t.Setenv("HEIMDAL_SESSION", "outer-session")
+    t.Setenv(secret.PassphraseEnv, "hunter2-hunter2")
+
+    tests := []struct {
+        profile string
+        want    []string // KEY or KEY=value lines that must appear
+        absent  []string
+    }{
+        {"restricted",
+            []string{"PATH", "LANG=C.UTF-8", "HEIMDAL_PROFILE=restricted", "HEIMDAL=1", "HEIMDAL_SESSION=<session>", "HEIMDAL_WORKDIR=" + dir, "DEMO_MODE=fast", "DEMO_TOKEN=secret://demo-token"},
+            []string{"EDITOR", "HEIMDAL_PROMPT_PREFIX", secret.PassphraseEnv}},
+        {"permissive",
+            []string{"PATH", "LANG=C.UTF-8", "EDITOR=vi", "HEIMDAL_PROFILE=restricted", "HEIMDAL_SESSION=<session>", "DEMO_MODE=fast"},
+            []string{"HEIMDAL_PROMPT_PREFIX", secret.PassphraseEnv}},
+    }
+    for _, tt := range tests {
+        out := captureStdout(t, func() error { return printEnv("demo", runOptions{profile: tt.profile}) })
+        env := map[string]string{}
+        for _, line := range strings.Split(out, "\n") {
+            if k, v, ok := strings.Cut(line, "="); ok && !strings.HasPrefix(line, "#") { env[k] = v }
+        }
+        for _, w := range tt.want {
+            k, v, hasValue := strings.Cut(w, "=")
+            got, ok := env[k]
+            if !ok || hasValue && got != v { t.Errorf("%s: want %s, got %s=%q\n%s", tt.profile, w, k, got, out) }
+        }
+        for _, k := range tt.absent {
+            if _, ok := env[k]; ok { t.Errorf("%s: %s should not be passed\n%s", tt.profile, k, out) }
+        }
+    }
+}
+
+func captureStdout(t *testing.T, f func() error) string {
+    t.Helper()
+    r, w, err := os.Pipe()
+    if err != nil { t.Fatal(err) }
+    stdout := os.Stdout
+    os.Stdout = w
+    ferr := f()
+    os.Stdout = stdout
+    w.Close()
+    b, err := io.ReadAll(r)
+    if err != nil { t.Fatal(err) }
+    if ferr != nil { t.Fatal(ferr) }
+    return string(b)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "heimdal/internal/audit"
+    "heimdal/internal/gate"
+    "heimdal/internal/policy"
+    "heimdal/internal/universe"
+)
+
+// gateCheck is the shell hook client:
+// `heimdal __gate-check <shell> <command> [<line>]`. Exit status 0 lets the
+// command run; anything else makes the hook skip it.
+func gateCheck(args []string) int {
+    if len(args) != 2 && len(args) != 3 {
+        fmt.Fprintln(os.Stderr, "usage: heimdal "+gate.CheckArg+" <shell> <command> [<line>]")
+        return 2
+    }
+    req := gate.Request{Shell: args[0], Command: args[1]}
+    req.Cwd, _ = os.Getwd()
+    if len(args) == 3 {
+        // bash reads the line from history, which may be a stale entry
+        // (e.g. with HISTCONTROL=ignorespace); only keep it if it fits.
+        line, cmd := policy.Normalize(args[2]), policy.Normalize(args[1])
+        if line != cmd && strings.Contains(line, cmd) { req.Line = args[2] }
+    }
+    ok, err := gate.Check(os.Getenv(gate.SocketEnv), req)
+    if err != nil {
+        // Fail closed: a gated shell whose heimdal is gone should not run
+        // commands unaudited.
+        fmt.Fprintln(os.Stderr, "heimdal: command gate:", err)
+        return 1
+    }
+    if !ok { return 1 }
+    return 0
+}
+
+// startShellGate serves the control socket for a heimdal shell session and
+// logs every command to alog. Commands are decided by policies.commands in
+// apps/shell.yaml, falling back to mode.
+func startShellGate(sess universe.Session, alog *audit.Logger, mode string) (*gate.Server, error) {
+    pol, err := commandPolicy("shell", policy.Action(mode))
+    if err != nil { return nil, err }
+    decide := func(req gate.Request) (gate.Decision, string) {
+        v := pol.Check(req.Command)
+        if req.Line != "" { v = policy.Strictest(v, pol.Check(req.Line)) }
+        reason := v.Reason
+        if v.Rule == 0 { reason = "shell_gate=" + mode }
+        return gate.Decision(v.Action), reason
+    }
+    record := func(req gate.Request, d gate.Decision, reason string, asked bool) {
+        _ = alog.Log(audit.Event{Kind: audit.KindCommand, App: "shell", Workdir: req.Cwd,
+            Command: req.Command, Decision: string(d), Reason: reason})
+    }
+    return gate.Listen(filepath.Join(sess.Dir, gate.SocketName), decide, record)
+}
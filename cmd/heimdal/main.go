@@ -6,24 +6,68 @@ import (
     "os"
     "os/exec"
     "path/filepath"
+    "strconv"
     "strings"
-    "runtime"
     "io/fs"
     "os/signal"
+    "runtime"
+    "time"
 
+    "heimdal/internal/adapter"
+    "heimdal/internal/audit"
     "heimdal/internal/config"
+    "heimdal/internal/gate"
+    "heimdal/internal/git"
     "heimdal/internal/manifest"
+    "heimdal/internal/policy"
+    "heimdal/internal/record"
+    "heimdal/internal/redact"
+    "heimdal/internal/sandbox"
+    "heimdal/internal/secret"
+    "heimdal/internal/trace"
     "heimdal/internal/universe"
     wikimod "heimdal/internal/wiki"
+
+    "golang.org/x/term"
 )
 
 func main() {
+    // Hidden re-exec entry point used by the restricted profile.
+    if len(os.Args) > 1 && os.Args[1] == sandbox.InitArg {
+        if err := sandbox.Init(os.Args[2:]); err != nil {
+            fmt.Fprintln(os.Stderr, "error:", err)
+            os.Exit(126)
+        }
+    }
+    // Hidden hook client used by heimdal shell's command gate.
+    if len(os.Args) > 1 && os.Args[1] == gate.CheckArg {
+        os.Exit(gateCheck(os.Args[2:]))
+    }
     if err := run(os.Args); err != nil {
+        var ee *exitError
+        if errors.As(err, &ee) {
+            if ee.err != nil { fmt.Fprintln(os.Stderr, "error:", ee.err) }
+            os.Exit(ee.code)
+        }
         fmt.Fprintln(os.Stderr, "error:", err)
         os.Exit(1)
     }
 }
 
+// exitError makes heimdal exit with a wrapped app's exit code. err is printed
+// first when set; a child that merely exited non-zero has already spoken.
+type exitError struct {
+    code int
+    err  error
+}
+
+func (e *exitError) Error() string {
+    if e.err != nil { return e.err.Error() }
+    return fmt.Sprintf("exit status %d", e.code)
+}
+
+func (e *exitError) Unwrap() error { return e.err }
+
 func run(argv []string) error {
     if len(argv) == 0 {
         return errors.New("no argv")
@@ -40,23 +84,34 @@ func run(argv []string) error {
     args := argv[1:]
 
     // global flags (very minimal): --profile=permissive|restricted, --prompt-prefix=...
-    profile := "permissive"
-    promptPrefix := "[hd] "
+    flags := map[string]string{}
     filtered := make([]string, 0, len(args))
     for i := 0; i < len(args); i++ {
         a := args[i]
         if strings.HasPrefix(a, "--profile=") {
-            profile = strings.TrimPrefix(a, "--profile=")
+            flags["profile"] = strings.TrimPrefix(a, "--profile=")
             continue
         }
         if strings.HasPrefix(a, "--prompt-prefix=") {
-            promptPrefix = strings.TrimPrefix(a, "--prompt-prefix=")
+            flags["prompt_prefix"] = strings.TrimPrefix(a, "--prompt-prefix=")
             continue
         }
         filtered = append(filtered, a)
     }
     args = filtered
 
+    // Layered config: defaults < ~/.heimdall/config.yaml < .heimdall.yaml < HEIMDAL_* env < flags
+    cwd, _ := os.Getwd()
+    cfg, err := config.Load(cwd, flags)
+    if err != nil { return err }
+    for _, w := range cfg.Warnings { fmt.Fprintf(os.Stderr, "[heimdal] config: %s\n", w) }
+    profile := cfg.String("profile")
+    promptPrefix := cfg.String("prompt_prefix")
+    red, err := redact.New(strings.Fields(cfg.String("redact_patterns")))
+    if err != nil { return err }
+    opts := runOptions{profile: profile, trace: cfg.String("exec_trace") == "on", secretStore: cfg.String("secret_store"), red: red,
+        checkpoint: cfg.String("git_checkpoint") == "on"}
+
     if len(args) == 0 {
         // No args: print help
         usage(prog)
@@ -68,25 +123,61 @@ func run(argv []string) error {
         usage(prog)
         return nil
     case "shell":
-        return cmdShell(promptPrefix)
+        return cmdShell(promptPrefix, profile, cfg.String("shell_gate"), red)
     case "run":
-        if len(args) < 2 {
-            return errors.New("usage: heimdal run <app> [args...]")
+        const usageRun = "usage: heimdal run [--session <id>] [--timeout <dur>] [--worktree] [--print-env] <app> [args...]"
+        rest := args[1:]
+        printEnvOnly := false
+        for len(rest) > 0 {
+            if strings.HasPrefix(rest[0], "--session=") {
+                opts.sessionID = strings.TrimPrefix(rest[0], "--session=")
+                rest = rest[1:]
+            } else if len(rest) > 1 && rest[0] == "--session" {
+                opts.sessionID = rest[1]
+                rest = rest[2:]
+            } else if rest[0] == "--worktree" {
+                opts.worktree = true
+                rest = rest[1:]
+            } else if rest[0] == "--print-env" {
+                printEnvOnly = true
+                rest = rest[1:]
+            } else if v, ok := strings.CutPrefix(rest[0], "--timeout="); ok || (rest[0] == "--timeout" && len(rest) > 1) {
+                n := 1
+                if !ok { v, n = rest[1], 2 }
+                d, err := time.ParseDuration(v)
+                if err != nil || d <= 0 { return fmt.Errorf("invalid --timeout %q (want e.g. 90s, 30m or 2h)", v) }
+                opts.timeout = d
+                rest = rest[n:]
+            } else {
+                break
+            }
         }
-        app := args[1]
-        rest := args[2:]
-        return cmdRun(app, rest, profile)
+        if len(rest) < 1 {
+            return errors.New(usageRun)
+        }
+        if printEnvOnly { return printEnv(rest[0], opts) }
+        return cmdRun(rest[0], rest[1:], opts)
     case "app":
         return cmdApp(args[1:])
     case "log":
         return cmdLog(args[1:])
+    case "session":
+        return cmdSession(args[1:])
     case "wiki":
-        return cmdWiki(args[1:])
+        return cmdWiki(cfg, args[1:])
+    case "config":
+        return cmdConfig(cfg, args[1:])
+    case "mcp":
+        return cmdMcp(cfg, args[1:])
+    case "policy":
+        return cmdPolicy(cfg, args[1:])
+    case "secret":
+        return cmdSecret(cfg, args[1:])
     default:
         // shorthand: heimdal <app> [args...]
         app := args[0]
         rest := args[1:]
-        return cmdRun(app, rest, profile)
+        return cmdRun(app, rest, opts)
     }
 }
 
@@ -95,28 +186,50 @@ func usage(prog string) {
 
 Usage:
   %s shell
-  %s run <app> [args...]
+  %s run [--session <id>] [--timeout <dur>] [--worktree] [--print-env] <app> [args...]
   %s app add <name> --cmd <cmd> [--args "--foo --bar"]
   %s app ls
   %s app rm <name>
-  %s wiki search <query>
+  %s log tail [--session <id>] [--follow] [-n <lines>]
+  %s session ls | show <id> | diff <id> [--stat] | rollback <id> [--yes] | replay <id> [--speed 2] | rm [--force] <id> | prune --older-than 7d [--force]
+  %s config show [--origin] | get <key> [--origin] | set [--project] <key> <value>
+  %s wiki search [--semantic|--hybrid] <query>
   %s wiki show <title>
+  %s wiki index
   %s wiki init
+  %s mcp serve [--session <id>]   (Model Context Protocol over stdio)
+  %s policy test <app> -- <command...>
+  %s secret set <name> [value] | get <name> | ls | rm <name>
   %s [--profile=permissive|restricted] [--prompt-prefix="[hd] "] <app> [args...]  (shorthand)
 
 Env/Config:
-  Apps manifests in apps/<name>.yaml. Minimal YAML supported: name, cmd, args, env.
+  Apps manifests in apps/<name>.yaml: name, cmd, args, env, env_passthrough, env_deny, policies, resources, timeout, idle_timeout, isolation. Unknown keys are errors.
+  Settings: defaults < ~/.heimdall/config.yaml < nearest .heimdall.yaml < HEIMDAL_* env < flags.
+  Session audit logs in ~/.heimdall/sessions/<id>/audit.jsonl.
 
-`, prog, prog, prog, prog, prog, prog)
+`, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog)
 }
 
-func cmdShell(prefix string) error {
+func cmdShell(prefix, profile, gateMode string, red *redact.Redactor) error {
     sh := os.Getenv("SHELL")
     if sh == "" {
         sh = "/bin/sh"
     }
     base := filepath.Base(sh)
 
+    cwd, _ := os.Getwd()
+    red.AddEnv(os.Environ())
+    sess, err := universe.StartSession(cwd, red)
+    if err != nil { return err }
+    meta, err := sess.Begin("shell", profile, cwd)
+    if err != nil { return err }
+    if err := sess.Baseline(cwd); err != nil { fmt.Fprintf(os.Stderr, "[heimdal] snapshot: %v\n", err) }
+    alog, err := audit.Open(sess.Dir, sess.ID)
+    if err != nil { return err }
+    defer alog.Close()
+    alog.Redact(red)
+    _ = alog.Log(audit.Event{Kind: audit.KindSessionStart, App: "shell", Profile: profile, Workdir: cwd})
+
     // Common env
     env := map[string]string{}
     for _, kv := range os.Environ() {
@@ -126,6 +239,25 @@ func cmdShell(prefix string) error {
     }
     env["HEIMDAL"] = "1"
     env["HEIMDAL_PREFIX"] = prefix
+    env["HEIMDAL_SESSION"] = sess.ID
+    env["HEIMDAL_CONTEXT_DIR"] = sess.ContextDir
+    env["HEIMDAL_WORKDIR"] = cwd
+
+    // Command gate: the rc shims below install a hook that asks heimdal
+    // about each command over the session's control socket.
+    if gateMode != "off" {
+        if base != "bash" && base != "zsh" {
+            fmt.Fprintf(os.Stderr, "[heimdal] shell_gate: %s has no command hook; commands are not gated\n", base)
+        } else {
+            srv, err := startShellGate(sess, alog, gateMode)
+            if err != nil { return err }
+            defer srv.Close()
+            self, err := os.Executable()
+            if err != nil { return err }
+            env[gate.SocketEnv] = filepath.Join(sess.Dir, gate.SocketName)
+            env["HEIMDAL_BIN"] = self
+        }
+    }
 
     var cmd *exec.Cmd
     cleanup := func() {}
@@ -149,6 +281,20 @@ function _heimdal_prompt_prefix() {
   fi
 }
 precmd_functions+=(_heimdal_prompt_prefix)
+# Command gate: preexec cannot cancel a command, so check the line on Enter.
+if [[ -n "$HEIMDAL_CONTROL_SOCKET" ]]; then
+  function _heimdal_accept_line() {
+    if [[ -n "${BUFFER//[[:space:]]/}" ]]; then
+      zle -I
+      if ! "$HEIMDAL_BIN" __gate-check zsh "$BUFFER" </dev/tty; then
+        print -s -- "$BUFFER"
+        BUFFER=""
+      fi
+    fi
+    zle .accept-line
+  }
+  zle -N accept-line _heimdal_accept_line
+fi
 `
         if err := os.WriteFile(filepath.Join(tmpDir, ".zshrc"), []byte(shim), fs.FileMode(0644)); err != nil {
             return err
@@ -175,6 +321,27 @@ __heimdal_ps1() {
   esac
 }
 PROMPT_COMMAND="__heimdal_ps1; ${PROMPT_COMMAND}"
+# Command gate: a DEBUG trap checks each simple command typed at the prompt;
+# with extdebug a non-zero trap skips it. The gate is disarmed while
+# PROMPT_COMMAND runs.
+if [ -n "$HEIMDAL_CONTROL_SOCKET" ]; then
+  __heimdal_arm() { __heimdal_armed=1; }
+  __heimdal_disarm() { __heimdal_armed=; __heimdal_line=; }
+  __heimdal_gate() {
+    [ -n "$__heimdal_armed" ] && [ -z "$COMP_LINE" ] || return 0
+    case "$BASH_COMMAND" in __heimdal_*) return 0;; esac
+    # The whole line too, so rules can see pipelines such as curl ... | sh.
+    if [ -z "$__heimdal_line" ]; then
+      __heimdal_line=$(HISTTIMEFORMAT= builtin history 1)
+      __heimdal_line=${__heimdal_line#*[0-9]  }
+    fi
+    "$HEIMDAL_BIN" __gate-check bash "$BASH_COMMAND" "$__heimdal_line"
+  }
+  shopt -s extdebug
+  trap '__heimdal_gate' DEBUG
+  PROMPT_COMMAND="__heimdal_disarm; ${PROMPT_COMMAND}
+__heimdal_arm"
+fi
 `
         if err := os.WriteFile(tmpPath, []byte(shim), fs.FileMode(0644)); err != nil { return err }
         cmd = exec.Command(sh, "--rcfile", tmpPath, "-i")
@@ -190,11 +357,6 @@ PROMPT_COMMAND="__heimdal_ps1; ${PROMPT_COMMAND}"
         cmd = exec.Command(sh)
     }
 
-    // Wire stdio
-    cmd.Stdin = os.Stdin
-    cmd.Stdout = os.Stdout
-    cmd.Stderr = os.Stderr
-
     // Rebuild env list
     envList := make([]string, 0, len(env))
     for k, v := range env {
@@ -202,77 +364,343 @@ PROMPT_COMMAND="__heimdal_ps1; ${PROMPT_COMMAND}"
     }
     cmd.Env = envList
 
-    // Ensure cleanup on Ctrl-C and normal exit
+    // Signals are forwarded to the shell by record.Run, so cleanup runs
+    // on normal exit and on Ctrl-C alike.
     defer cleanup()
-    c := make(chan os.Signal, 1)
-    signal.Notify(c, os.Interrupt)
-    go func() {
-        <-c
-        cleanup()
-        if cmd.Process != nil && runtime.GOOS != "windows" {
-            _ = cmd.Process.Signal(os.Interrupt)
-        }
-    }()
 
-    return cmd.Run()
+    injected := map[string]string{}
+    for k, v := range env {
+        if strings.HasPrefix(k, "HEIMDAL") { injected[k] = v }
+    }
+    _ = alog.Log(audit.Event{Kind: audit.KindExec, App: "shell", Argv: cmd.Args,
+        EnvKeys: audit.SortedKeys(injected)})
+    width, height := record.Size()
+    cast, err := record.Create(record.Path(sess.Dir, meta.Runs), width, height, "heimdal shell")
+    if err != nil { return err }
+    cast.Redact(red)
+    start := time.Now()
+    err = record.Run(cmd, cast, nil)
+    _ = cast.Close()
+    use := sandbox.ProcessUsage(cmd.ProcessState)
+    return finishRun(sess, meta, alog, "shell", nil, &use, start, err)
+}
+
+// runOptions are the settings and flags that shape a cmdRun.
+type runOptions struct {
+    profile   string
+    sessionID string // reuse this session instead of starting one
+    trace     bool   // record execs by the app's descendants
+    // secretStore is the backend for secret:// env values.
+    secretStore string
+    red         *redact.Redactor // masks secrets in everything the run writes
+    timeout     time.Duration    // overrides the manifest's timeout when set
+    checkpoint  bool             // commit the git working tree before the first run
+    worktree    bool             // run in a git worktree of the session's own
+}
+
+// checkProfile rejects profiles other than permissive and restricted.
+func checkProfile(profile string) error {
+    switch profile {
+    case "permissive", "restricted":
+        return nil
+    }
+    return fmt.Errorf("unknown profile: %s (want permissive|restricted)", profile)
 }
 
-func cmdRun(app string, rest []string, profile string) error {
-    // Create a Heimdal universe session and context
+func cmdRun(app string, rest []string, opts runOptions) error {
+    profile, sessionID := opts.profile, opts.sessionID
+    // Checked before the session starts, so a bad profile leaves no half-begun run.
+    if err := checkProfile(profile); err != nil { return err }
+    // Create a Heimdal universe session and context, or reuse an existing one
     cwd, _ := os.Getwd()
-    sess, err := universe.StartSession(cwd)
+    // Secrets in the host env are masked from the start; manifest values are
+    // added as they are resolved, before anything can write them down.
+    red := opts.red
+    red.AddEnv(os.Environ())
+    var sess universe.Session
+    var err error
+    if sessionID != "" {
+        sess, err = universe.OpenSession(cwd, sessionID)
+        sess.Redactor = red
+    } else {
+        sess, err = universe.StartSession(cwd, red)
+    }
+    if err != nil { return err }
+    meta, err := sess.Begin(app, profile, cwd)
     if err != nil { return err }
 
-    appsDir, err := config.EnsureAppsDir()
+    alog, err := audit.Open(sess.Dir, sess.ID)
+    if err != nil { return err }
+    defer alog.Close()
+    alog.Redact(red)
+    _ = alog.Log(audit.Event{Kind: audit.KindSessionStart, App: app, Profile: profile, Workdir: cwd})
+
+    m, maniPath, err := findManifest(app)
     if err != nil {
-        return err
+        return finishRun(sess, meta, alog, app, nil, nil, time.Now(), err)
     }
-    maniPath := filepath.Join(appsDir, app+".yaml")
-    var m manifest.Manifest
-    if _, err := os.Stat(maniPath); err == nil {
-        m, err = manifest.Load(maniPath)
-        if err != nil {
-            return fmt.Errorf("load manifest: %w", err)
+    _ = alog.Log(audit.Event{Kind: audit.KindManifest, App: app, Manifest: &audit.ManifestInfo{
+        Name: m.Name, Cmd: m.Cmd, Args: m.Args, EnvKeys: audit.SortedKeys(m.Env), Path: maniPath,
+    }})
+
+    pol, err := policy.New(m.Policies.Commands, policy.Allow)
+    if err != nil {
+        return finishRun(sess, meta, alog, app, nil, nil, time.Now(), fmt.Errorf("%s: %w", maniPath, err))
+    }
+
+    // Worktree isolation: from here on the app's workdir is its worktree.
+    var wt *worktreeRun
+    if opts.worktree || m.Isolation == "worktree" || meta.Worktree != "" {
+        if wt, err = openWorktree(sess, &meta, cwd); err != nil {
+            return finishRun(sess, meta, alog, app, nil, nil, time.Now(), fmt.Errorf("worktree: %w", err))
         }
-    } else {
-        // Fallback: treat name as command directly
-        m = manifest.Manifest{Name: app, Cmd: app}
+        defer wt.finish(sess, app)
+        cwd = meta.Worktree
     }
+    // Baseline for the changes recorded when the run ends, taken in the
+    // directory the app actually runs in.
+    if err := sess.Baseline(meta.RunDir()); err != nil { fmt.Fprintf(os.Stderr, "[heimdal] snapshot: %v\n", err) }
 
     // Build command and args
     cmdName := m.Cmd
     cmdArgs := append([]string{}, m.Args...)
     cmdArgs = append(cmdArgs, rest...)
 
-    // Env: start from host env, then overlay Heimdal universe env, then manifest env
-    envMap := map[string]string{}
-    for _, kv := range os.Environ() {
-        if i := strings.IndexByte(kv, '='); i >= 0 {
-            envMap[kv[:i]] = kv[i+1:]
+    // Env: the host env filtered for the profile, then the universe vars,
+    // then the manifest's env.
+    uni := universeEnv(sess.ID, sess.ContextDir, cwd)
+    if m.Context.MCP {
+        if p, err := writeMCPConfig(sess); err == nil {
+            uni["HEIMDAL_MCP_CONFIG"] = p
+        } else {
+            fmt.Fprintf(os.Stderr, "[heimdal] mcp: %v\n", err)
         }
     }
-    envMap["HEIMDAL"] = "1"
-    envMap["HEIMDAL_UNIVERSE"] = "1"
-    envMap["HEIMDAL_SESSION"] = sess.ID
-    envMap["HEIMDAL_CONTEXT_DIR"] = sess.ContextDir
-    envMap["HEIMDAL_WORKDIR"] = cwd
-    for k, v := range m.Env {
-        envMap[k] = os.ExpandEnv(v)
+    var store secret.Store
+    envMap, injected, err := appEnv(m, profile, uni, func(k, v string) (string, error) {
+        if _, ok := secret.ParseRef(v); ok {
+            v, err := resolveSecret(&store, opts.secretStore, k, v)
+            if err != nil { return "", err }
+            red.Add(v)
+            return v, nil
+        }
+        v = os.ExpandEnv(v)
+        red.AddSensitive(k, v)
+        return v, nil
+    })
+    if err != nil {
+        return finishRun(sess, meta, alog, app, nil, nil, time.Now(), err)
+    }
+
+    writeWikiContext(sess, m, app, meta.Workdir)
+
+    // Adapter: hand the session context to known AI CLIs their own way.
+    ad, err := adapter.Resolve(m.Adapter, cmdName)
+    if err != nil {
+        return finishRun(sess, meta, alog, app, nil, nil, time.Now(), err)
+    }
+    var adapterName, appVersion string
+    if ad != nil {
+        adapterName = ad.Name()
+        appVersion, _ = ad.Version(cmdName) // best effort; a missing binary fails below
+        plan, err := ad.Prepare(adapter.Context{SessionID: sess.ID, ContextDir: sess.ContextDir, Workdir: cwd,
+            MCPConfig: envMap["HEIMDAL_MCP_CONFIG"]}, cmdArgs)
+        if err != nil {
+            return finishRun(sess, meta, alog, app, ad, nil, time.Now(), fmt.Errorf("adapter %s: %w", adapterName, err))
+        }
+        cmdArgs = append(plan.Args, cmdArgs...)
+        for k, v := range plan.Env {
+            envMap[k] = v
+            injected[k] = v
+        }
     }
     envList := make([]string, 0, len(envMap))
     for k, v := range envMap {
         envList = append(envList, k+"="+v)
     }
 
-    fmt.Fprintf(os.Stderr, "[heimdal] running app=%s cmd=%s profile=%s\n", app, cmdName, profile)
+    var spec sandbox.Spec
+    switch profile {
+    case "permissive":
+    case "restricted":
+        spec.IsolateNetwork = !m.Policies.NetworkAllowed()
+        spec.FSRead = sandbox.ResolvePaths(cwd, m.Policies.Filesystem.Read)
+        spec.FSWrite = sandbox.ResolvePaths(cwd, m.Policies.Filesystem.Write)
+        for _, dir := range spec.FSWrite {
+            // Write targets such as ./.heimdall-cache may not exist yet.
+            _ = os.MkdirAll(dir, 0o755)
+        }
+    }
+    // Resources: a delegated cgroup v2 group when possible, else rlimits.
+    memMax, _ := m.Resources.MemoryBytes() // validated by manifest.Load
+    limits := sandbox.Limits{MemoryMax: memMax, CPUMax: m.Resources.CPU, PidsMax: m.Resources.Pids, IOWeight: m.Resources.IOWeight}
+    var cg *sandbox.Cgroup
+    if !limits.Empty() {
+        if cg, err = sandbox.NewCgroup(fmt.Sprintf("heimdal-%s-%d", sess.ID, meta.Runs), limits); err != nil {
+            if sandbox.RlimitsSupported {
+                fmt.Fprintf(os.Stderr, "[heimdal] resources: no cgroup v2 delegation (%v); falling back to setrlimit%s\n", err, rlimitCaveats(limits))
+                spec.Rlimits = limits
+            } else {
+                fmt.Fprintf(os.Stderr, "[heimdal] resources: limits are not supported on %s; running without them\n", runtime.GOOS)
+            }
+        }
+    }
+
+    if opts.checkpoint && wt == nil && meta.Checkpoint == "" {
+        if commit, root, err := checkpoint(sess.ID, cwd, app); err == nil {
+            meta.Checkpoint, meta.CheckpointRepo = commit, root
+            _ = sess.SaveMeta(meta)
+            fmt.Fprintf(os.Stderr, "[heimdal] checkpoint %s saved; undo this session with `heimdal session rollback %s`\n", git.Short(commit), sess.ID)
+        } else if !errors.Is(err, git.ErrNotRepo) {
+            fmt.Fprintf(os.Stderr, "[heimdal] checkpoint: %v\n", err)
+        }
+    }
+
+    fmt.Fprintf(os.Stderr, "[heimdal] running app=%s cmd=%s profile=%s", app, cmdName, profile)
+    if ad != nil { fmt.Fprintf(os.Stderr, " adapter=%s %s", adapterName, appVersion) }
+    fmt.Fprintln(os.Stderr)
 
-    cmd := exec.Command(cmdName, cmdArgs...)
-    cmd.Stdin = os.Stdin
-    cmd.Stdout = os.Stdout
-    cmd.Stderr = os.Stderr
+    cmd, err := sandbox.Command(spec, cmdName, cmdArgs...)
+    if err != nil {
+        return finishRun(sess, meta, alog, app, ad, nil, time.Now(), err)
+    }
     cmd.Env = envList
+    cmd.Dir = cwd
+    isolation := spec.Describe()
+    if wt != nil { isolation = append(isolation, "worktree:"+wt.branch) }
+    if cg != nil {
+        cg.Attach(cmd)
+        defer cg.Remove()
+        isolation = append(isolation, "cgroup:"+strings.Join(limits.Describe(), ","))
+    }
+
+    _ = alog.Log(audit.Event{Kind: audit.KindExec, App: app, Argv: append([]string{cmdName}, cmdArgs...),
+        EnvKeys: audit.SortedKeys(injected), Isolation: isolation, Adapter: adapterName, AppVersion: appVersion})
+    width, height := record.Size()
+    cast, err := record.Create(record.Path(sess.Dir, meta.Runs), width, height, "heimdal run "+app)
+    if err != nil { return finishRun(sess, meta, alog, app, ad, nil, time.Now(), err) }
+    cast.Redact(red)
+    var timeouts record.Timeouts
+    timeouts.Total, timeouts.Idle = m.Timeouts()
+    if opts.timeout > 0 { timeouts.Total = opts.timeout }
+    var tracer *trace.Tracer
+    var stopWatch func(error) error
+    started := func(pid int) {
+        if timeouts.On() { stopWatch = record.Watch(pid, cast, timeouts) }
+        if !opts.trace { return }
+        var terr error
+        if tracer, terr = startExecTrace(pid, alog, app, pol); terr != nil {
+            fmt.Fprintf(os.Stderr, "[heimdal] exec trace: %v\n", terr)
+        }
+    }
+    start := time.Now()
+    err = record.Run(cmd, cast, started)
+    if stopWatch != nil { err = stopWatch(err) }
+    if tracer != nil { tracer.Stop() }
+    _ = cast.Close()
+    use := sandbox.ProcessUsage(cmd.ProcessState)
+    if cg != nil {
+        // The cgroup also counts descendants that were never waited for.
+        cu := cg.Usage()
+        if cu.PeakMemory > 0 { use.PeakMemory, use.Source = cu.PeakMemory, cu.Source }
+        if cu.CPU > use.CPU { use.CPU = cu.CPU }
+        use.OOMKills = cu.OOMKills
+    }
+    return finishRun(sess, meta, alog, app, ad, &use, start, err)
+}
+
+// rlimitCaveats says how the setrlimit fallback falls short of l.
+func rlimitCaveats(l sandbox.Limits) string {
+    var notes []string
+    if l.MemoryMax > 0 { notes = append(notes, "memory caps each process's data size") }
+    if l.PidsMax > 0 { notes = append(notes, "pids caps the user's processes (RLIMIT_NPROC)") }
+    if l.CPUMax > 0 { notes = append(notes, "cpu is not enforced") }
+    if l.IOWeight > 0 { notes = append(notes, "io_weight is not enforced") }
+    return ": " + strings.Join(notes, "; ")
+}
+
+// findManifest loads apps/<app>.yaml. Without one, app is run from PATH and
+// path is "".
+func findManifest(app string) (m manifest.Manifest, path string, err error) {
+    appsDir, err := config.EnsureAppsDir()
+    if err != nil { return m, "", err }
+    path = filepath.Join(appsDir, app+".yaml")
+    if _, err := os.Stat(path); err != nil {
+        // Fallback: treat name as command directly
+        return manifest.Manifest{Name: app, Cmd: app}, "", nil
+    }
+    m, err = manifest.Load(path)
+    if err != nil { return m, path, fmt.Errorf("load manifest: %w", err) }
+    return m, path, nil
+}
 
-    return cmd.Run()
+// writeWikiContext writes context/wiki.md with the wiki pages most relevant
+// to the app, its manifest tags and the repo. Missing wikis are not an error.
+func writeWikiContext(sess universe.Session, m manifest.Manifest, app, cwd string) {
+    wc := m.Context.Wiki
+    if !wc.On() { return }
+    path, err := wikimod.Locate(cwd)
+    if err != nil { return }
+    db, err := wikimod.Load(path)
+    if err != nil || len(db.Pages) == 0 { return }
+
+    terms := []string{app, m.Name, filepath.Base(m.Cmd), filepath.Base(cwd)}
+    terms = append(terms, m.Tags...)
+    terms = append(terms, wc.Queries...)
+    seen := map[string]bool{}
+    var words []string
+    for _, t := range terms {
+        t = strings.TrimSpace(t)
+        if t == "" || seen[strings.ToLower(t)] { continue }
+        seen[strings.ToLower(t)] = true
+        words = append(words, t)
+    }
+    maxPages, maxTokens := wc.MaxPages, wc.MaxTokens
+    if maxPages <= 0 { maxPages = 5 }
+    if maxTokens <= 0 { maxTokens = 4000 }
+    doc, titles := wikimod.SelectContext(db, strings.Join(words, " "), maxPages, maxTokens)
+    if len(titles) == 0 { return }
+    _ = sess.WriteContext("wiki.md", doc)
+}
+
+// finishRun records the outcome of a wrapped process in the audit log and
+// session metadata, and turns it into heimdal's own exit status. ad, when
+// set, explains the app's exit code; use, when set, is what the app consumed.
+func finishRun(sess universe.Session, meta universe.Meta, alog *audit.Logger, app string, ad adapter.Adapter, use *sandbox.Usage, start time.Time, err error) error {
+    code, sig := record.ExitStatus(err)
+    ev := audit.Event{Kind: audit.KindExit, App: app, DurationMS: time.Since(start).Milliseconds(), ExitCode: &code, Signal: sig}
+    var te *record.TimeoutError
+    if errors.As(err, &te) {
+        ev.Reason = te.Error()
+        meta.TimedOut = true
+        fmt.Fprintf(os.Stderr, "[heimdal] %s stopped: %s\n", app, ev.Reason)
+    } else if ad != nil && code != 0 && sig == "" {
+        ev.Reason = ad.ExitReason(code)
+        if ev.Reason != "" { fmt.Fprintf(os.Stderr, "[heimdal] %s exited %d: %s\n", app, code, ev.Reason) }
+    }
+    if use != nil {
+        // The app ran, so it may have changed the workdir.
+        if changes, err := sess.RecordChanges(meta.RunDir()); err != nil {
+            fmt.Fprintf(os.Stderr, "[heimdal] snapshot: %v\n", err)
+        } else if len(changes) > 0 {
+            fmt.Fprintf(os.Stderr, "[heimdal] %s; see `heimdal session diff %s`\n", changeSummary(changes), sess.ID)
+        }
+        ev.PeakMemory, ev.CPUMS = use.PeakMemory, use.CPU.Milliseconds()
+        meta.PeakMemory, meta.CPUMS = ev.PeakMemory, ev.CPUMS
+        if use.OOMKills > 0 && ev.Reason == "" {
+            ev.Reason = fmt.Sprintf("memory limit reached: %d process(es) OOM-killed", use.OOMKills)
+            fmt.Fprintf(os.Stderr, "[heimdal] %s: %s\n", app, ev.Reason)
+        }
+    }
+    if err != nil { ev.Error = err.Error() }
+    _ = alog.Log(ev)
+    _ = sess.End(meta, code, sig)
+    if err == nil { return nil }
+    var ee *exec.ExitError
+    if errors.As(err, &ee) || te != nil {
+        return &exitError{code: code}
+    }
+    return &exitError{code: code, err: err}
 }
 
 func cmdApp(args []string) error {
@@ -339,16 +767,60 @@ func cmdApp(args []string) error {
 }
 
 func cmdLog(args []string) error {
-    if len(args) == 0 || args[0] == "tail" {
-        // Placeholder: print note for now.
-        fmt.Println("log tail: not implemented in MVP. Future: stream session logs.")
-        return nil
+    const usageLog = "usage: heimdal log tail [--session <id>] [--follow] [-n <lines>]"
+    if len(args) > 0 && args[0] != "tail" {
+        return errors.New(usageLog)
+    }
+    if len(args) > 0 { args = args[1:] }
+    var sid string
+    follow := false
+    n := 20
+    for i := 0; i < len(args); i++ {
+        a := args[i]
+        switch {
+        case a == "--session" && i+1 < len(args):
+            sid = args[i+1]
+            i++
+        case strings.HasPrefix(a, "--session="):
+            sid = strings.TrimPrefix(a, "--session=")
+        case a == "--follow" || a == "-f":
+            follow = true
+        case a == "-n" && i+1 < len(args):
+            v, err := strconv.Atoi(args[i+1])
+            if err != nil { return errors.New(usageLog) }
+            n = v
+            i++
+        default:
+            return errors.New(usageLog)
+        }
+    }
+    cwd, _ := os.Getwd()
+    if sid == "" {
+        latest, err := universe.Latest(cwd, audit.FileName)
+        if err != nil { return err }
+        sid = latest
+    }
+    path := filepath.Join(universe.SessionsDir(cwd), sid, audit.FileName)
+    if _, err := os.Stat(path); err != nil {
+        return fmt.Errorf("no audit log for session %s", sid)
+    }
+
+    stop := make(chan struct{})
+    if follow {
+        c := make(chan os.Signal, 1)
+        signal.Notify(c, os.Interrupt)
+        defer signal.Stop(c)
+        go func() {
+            <-c
+            close(stop)
+        }()
     }
-    return errors.New("usage: heimdal log tail")
+    return audit.Tail(path, os.Stdout, n, follow, stop)
 }
 
-func cmdWiki(args []string) error {
-    if len(args) == 0 { return errors.New("usage: heimdal wiki [search|show|init] ...") }
+func cmdWiki(cfg *config.Config, args []string) error {
+    const usageWiki = "usage: heimdal wiki [search [--semantic|--hybrid] <query>|show <title>|index|init] ..."
+    if len(args) == 0 { return errors.New(usageWiki) }
     sub := args[0]
     cwd, _ := os.Getwd()
     path, err := wikimod.Locate(cwd)
@@ -359,20 +831,41 @@ func cmdWiki(args []string) error {
         fmt.Println("initialized wiki at:", path)
         return nil
     case "search":
-        if len(args) < 2 { return errors.New("usage: heimdal wiki search <query>") }
-        return wikiSearch(path, strings.Join(args[1:], " "))
+        mode := ""
+        var words []string
+        for _, a := range args[1:] {
+            if a == "--semantic" || a == "--hybrid" {
+                mode = strings.TrimPrefix(a, "--")
+                continue
+            }
+            words = append(words, a)
+        }
+        if len(words) == 0 { return errors.New("usage: heimdal wiki search [--semantic|--hybrid] <query>") }
+        return wikiSearch(cfg, path, strings.Join(words, " "), mode)
     case "show":
         if len(args) < 2 { return errors.New("usage: heimdal wiki show <title>") }
         return wikiShow(path, strings.Join(args[1:], " "))
+    case "index":
+        db, err := wikimod.Load(path)
+        if err != nil { return err }
+        emb, err := wikimod.NewEmbedder(cfg.String("wiki_embedder"))
+        if err != nil { return err }
+        vi, _, err := wikimod.UpdateVectors(db, emb, nil)
+        if err != nil { return err }
+        vpath := wikimod.VectorsPath(path)
+        if err := wikimod.SaveVectors(vpath, vi); err != nil { return err }
+        fmt.Printf("indexed %d page(s) with %s: %s\n", len(vi.Entries), emb.Name(), vpath)
+        return nil
     default:
-        return errors.New("usage: heimdal wiki [search|show|init] ...")
+        return errors.New(usageWiki)
     }
 }
 
-func wikiSearch(path, query string) error {
-    db, err := wikimod.Load(path)
+// wikiSearch prints the results of a lexical (mode ""), semantic or hybrid
+// search.
+func wikiSearch(cfg *config.Config, path, query, mode string) error {
+    results, err := searchWiki(cfg, path, query, mode, 10)
     if err != nil { return err }
-    results := wikimod.Search(db, query, 10)
     if len(results) == 0 {
         fmt.Println("no results")
         return nil
@@ -383,11 +876,41 @@ func wikiSearch(path, query string) error {
     return nil
 }
 
+// searchWiki runs a lexical (mode ""), semantic or hybrid search. The vector
+// index is refreshed for changed pages and saved back when possible.
+func searchWiki(cfg *config.Config, path, query, mode string, limit int) ([]wikimod.Result, error) {
+    db, err := wikimod.Load(path)
+    if err != nil { return nil, err }
+    if mode == "" {
+        return wikimod.Search(db, query, limit), nil
+    }
+    emb, err := wikimod.NewEmbedder(cfg.String("wiki_embedder"))
+    if err != nil { return nil, err }
+    vpath := wikimod.VectorsPath(path)
+    prev, err := wikimod.LoadVectors(vpath)
+    if err != nil { prev = nil } // corrupt index: rebuild
+    vi, changed, err := wikimod.UpdateVectors(db, emb, prev)
+    if err != nil { return nil, err }
+    if changed {
+        // Best effort: a read-only wiki still searches fine.
+        _ = wikimod.SaveVectors(vpath, vi)
+    }
+    if mode == "semantic" {
+        return wikimod.SemanticSearch(db, vi, emb, query, limit)
+    }
+    return wikimod.HybridSearch(db, vi, emb, query, limit)
+}
+
 func wikiShow(path, title string) error {
     db, err := wikimod.Load(path)
     if err != nil { return err }
     if p, ok := wikimod.Show(db, title); ok {
-        fmt.Printf("# %s\n\n%s\n", p.Title, p.Content)
+        color := term.IsTerminal(int(os.Stdout.Fd())) && os.Getenv("NO_COLOR") == ""
+        content := p.Content
+        if !strings.HasPrefix(content, "# ") {
+            content = "# " + p.Title + "\n\n" + content
+        }
+        wikimod.Render(os.Stdout, content, color)
         return nil
     }
     return fmt.Errorf("page not found: %s", title)
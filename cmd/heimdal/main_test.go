@@ -0,0 +1,22 @@
+package main
+
+import (
+    "os"
+    "strings"
+    "testing"
+
+    "heimdal/internal/universe"
+)
+
+func TestRunUnknownProfile(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    dir := t.TempDir()
+    wd, _ := os.Getwd()
+    if err := os.Chdir(dir); err != nil { t.Fatal(err) }
+    defer os.Chdir(wd)
+
+    err := cmdRun("true", nil, runOptions{profile: "sandboxed"})
+    if err == nil || !strings.Contains(err.Error(), "unknown profile") { t.Fatalf("cmdRun = %v, want an unknown profile error", err) }
+    // No session is left behind in the running state.
+    if metas, err := universe.ListSessions(dir); err != nil || len(metas) != 0 { t.Errorf("sessions after a rejected run: %+v %v", metas, err) }
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+
+    "heimdal/internal/config"
+    "heimdal/internal/mcp"
+    "heimdal/internal/universe"
+    wikimod "heimdal/internal/wiki"
+)
+
+const usageMcp = "usage: heimdal mcp serve [--session <id>]"
+
+// mcpConfigFile is the context file registering heimdal's MCP server, in the
+// mcpServers format understood by the Claude and Gemini CLIs.
+const mcpConfigFile = "mcp.json"
+
+func cmdMcp(cfg *config.Config, args []string) error {
+    if len(args) == 0 || args[0] != "serve" { return errors.New(usageMcp) }
+    sessionID := os.Getenv("HEIMDAL_SESSION")
+    rest := args[1:]
+    for i := 0; i < len(rest); i++ {
+        a := rest[i]
+        switch {
+        case a == "--session" && i+1 < len(rest):
+            sessionID = rest[i+1]
+            i++
+        case strings.HasPrefix(a, "--session="):
+            sessionID = strings.TrimPrefix(a, "--session=")
+        default:
+            return errors.New(usageMcp)
+        }
+    }
+    workdir := os.Getenv("HEIMDAL_WORKDIR")
+    if workdir == "" { workdir, _ = os.Getwd() }
+    s := newMCPServer(cfg, workdir, sessionID)
+    // stdout carries the protocol; anything else goes to stderr.
+    return s.Serve(os.Stdin, os.Stdout)
+}
+
+// newMCPServer exposes the wiki and the session universe rooted at workdir.
+// sessionID may be empty, in which case the most recent session is used.
+func newMCPServer(cfg *config.Config, workdir, sessionID string) *mcp.Server {
+    s := mcp.NewServer("heimdal", "0.1.0")
+    s.AddTool(mcp.Tool{
+        Name:        "wiki_search",
+        Description: "Search the Heimdal wiki (local manpages and notes). Returns matching page titles with snippets.",
+        InputSchema: mcp.Schema(map[string][2]string{
+            "query": {"string", `Search terms; quote a phrase to require it`},
+            "mode":  {"string", "lexical (default), semantic or hybrid"},
+            "limit": {"integer", "Maximum results (default 10)"},
+        }, "query"),
+        Handler: func(raw json.RawMessage) (string, error) {
+            var a struct {
+                Query string `json:"query"`
+                Mode  string `json:"mode"`
+                Limit int    `json:"limit"`
+            }
+            if err := json.Unmarshal(raw, &a); err != nil { return "", err }
+            if strings.TrimSpace(a.Query) == "" { return "", errors.New("query is required") }
+            mode := a.Mode
+            switch mode {
+            case "", "lexical":
+                mode = ""
+            case "semantic", "hybrid":
+            default:
+                return "", fmt.Errorf("unknown mode %q (want lexical|semantic|hybrid)", a.Mode)
+            }
+            if a.Limit <= 0 { a.Limit = 10 }
+            wpath, err := wikimod.Locate(workdir)
+            if err != nil { return "", err }
+            results, err := searchWiki(cfg, wpath, a.Query, mode, a.Limit)
+            if err != nil { return "", err }
+            if len(results) == 0 { return "no results", nil }
+            var b strings.Builder
+            for _, r := range results {
+                fmt.Fprintf(&b, "- %s\n  %s\n", r.Title, r.Snippet)
+            }
+            return b.String(), nil
+        },
+    })
+    s.AddTool(mcp.Tool{
+        Name:        "wiki_show",
+        Description: "Return the full Markdown of a wiki page by title or alias.",
+        InputSchema: mcp.Schema(map[string][2]string{
+            "title": {"string", "Page title or alias"},
+        }, "title"),
+        Handler: func(raw json.RawMessage) (string, error) {
+            var a struct {
+                Title string `json:"title"`
+            }
+            if err := json.Unmarshal(raw, &a); err != nil { return "", err }
+            wpath, err := wikimod.Locate(workdir)
+            if err != nil { return "", err }
+            db, err := wikimod.Load(wpath)
+            if err != nil { return "", err }
+            p, ok := wikimod.Show(db, a.Title)
+            if !ok { return "", fmt.Errorf("page not found: %s", a.Title) }
+            if strings.HasPrefix(p.Content, "# ") { return p.Content, nil }
+            return "# " + p.Title + "\n\n" + p.Content, nil
+        },
+    })
+    s.AddTool(mcp.Tool{
+        Name:        "session_context",
+        Description: "List the current Heimdal session's context files, or return one of them (e.g. system.md, wiki.md, repo_files.txt).",
+        InputSchema: mcp.Schema(map[string][2]string{
+            "file": {"string", "Context file to read; omit to list files"},
+        }),
+        Handler: func(raw json.RawMessage) (string, error) {
+            var a struct {
+                File string `json:"file"`
+            }
+            if err := json.Unmarshal(raw, &a); err != nil { return "", err }
+            sess, err := mcpSession(workdir, sessionID)
+            if err != nil { return "", err }
+            if a.File == "" {
+                entries, err := os.ReadDir(sess.ContextDir)
+                if err != nil { return "", err }
+                var b strings.Builder
+                fmt.Fprintf(&b, "session %s context files:\n", sess.ID)
+                for _, e := range entries {
+                    if e.IsDir() { continue }
+                    if fi, err := e.Info(); err == nil {
+                        fmt.Fprintf(&b, "- %s (%d bytes)\n", e.Name(), fi.Size())
+                    }
+                }
+                return b.String(), nil
+            }
+            name := filepath.FromSlash(a.File)
+            if !filepath.IsLocal(name) { return "", fmt.Errorf("invalid context file %q", a.File) }
+            data, err := os.ReadFile(filepath.Join(sess.ContextDir, name))
+            if err != nil { return "", err }
+            return string(data), nil
+        },
+    })
+    s.AddTool(mcp.Tool{
+        Name:        "repo_files",
+        Description: "List files in the repository heimdal is running in, skipping VCS, dependency and build dirs.",
+        InputSchema: mcp.Schema(map[string][2]string{
+            "pattern": {"string", "Glob matched against the relative path or the file name, e.g. *.go or internal/*/*.go"},
+            "limit":   {"integer", "Maximum files (default 200)"},
+        }),
+        Handler: func(raw json.RawMessage) (string, error) {
+            var a struct {
+                Pattern string `json:"pattern"`
+                Limit   int    `json:"limit"`
+            }
+            if err := json.Unmarshal(raw, &a); err != nil { return "", err }
+            if a.Limit <= 0 { a.Limit = 200 }
+            if a.Pattern != "" {
+                if _, err := path.Match(a.Pattern, ""); err != nil { return "", fmt.Errorf("bad pattern %q: %w", a.Pattern, err) }
+            }
+            var out []string
+            for _, f := range universe.RepoFiles(workdir, 20000) {
+                if a.Pattern != "" {
+                    full, _ := path.Match(a.Pattern, f)
+                    base, _ := path.Match(a.Pattern, path.Base(f))
+                    if !full && !base { continue }
+                }
+                out = append(out, f)
+                if len(out) >= a.Limit { break }
+            }
+            if len(out) == 0 { return "no files", nil }
+            return strings.Join(out, "\n") + "\n", nil
+        },
+    })
+    return s
+}
+
+// mcpSession resolves the session the server reports on: the given ID (or
+// prefix), else the most recently started session.
+func mcpSession(workdir, id string) (universe.Session, error) {
+    if id == "" {
+        latest, err := universe.Latest(workdir, universe.MetaFile)
+        if err != nil { return universe.Session{}, err }
+        id = latest
+    }
+    return universe.OpenSession(workdir, id)
+}
+
+// writeMCPConfig registers `heimdal mcp serve` for sess in its context dir
+// and returns the file's path.
+func writeMCPConfig(sess universe.Session) (string, error) {
+    exe, err := os.Executable()
+    if err != nil { return "", err }
+    conf := map[string]interface{}{
+        "mcpServers": map[string]interface{}{
+            "heimdal": map[string]interface{}{
+                "command": exe,
+                "args":    []string{"mcp", "serve", "--session", sess.ID},
+            },
+        },
+    }
+    b, err := json.MarshalIndent(conf, "", "  ")
+    if err != nil { return "", err }
+    if err := sess.WriteContext(mcpConfigFile, string(b)+"\n"); err != nil { return "", err }
+    return filepath.Join(sess.ContextDir, mcpConfigFile), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "heimdal/internal/universe"
+)
+
+func TestSessionContextLocalPaths(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    workdir := t.TempDir()
+    sess, err := universe.StartSession(workdir, nil)
+    if err != nil { t.Fatal(err) }
+    if err := sess.WriteContext("notes.md", "context notes\n"); err != nil { t.Fatal(err) }
+    if err := os.WriteFile(filepath.Join(sess.Dir, "outside.txt"), []byte("not context\n"), 0o644); err != nil { t.Fatal(err) }
+    s := newMCPServer(nil, workdir, sess.ID)
+
+    tests := []struct {
+        file, want string
+        isError    bool
+    }{
+        {"notes.md", "context notes\n", false},
+        {"./notes.md", "context notes\n", false},
+        {"../outside.txt", `invalid context file "../outside.txt"`, true},
+        {"sub/../../outside.txt", `invalid context file "sub/../../outside.txt"`, true},
+        {filepath.Join(sess.Dir, "outside.txt"), "invalid context file", true},
+        {"/etc/passwd", `invalid context file "/etc/passwd"`, true},
+    }
+    var in strings.Builder
+    for i, tt := range tests {
+        args, _ := json.Marshal(map[string]string{"file": tt.file})
+        fmt.Fprintf(&in, `{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"session_context","arguments":%s}}`+"\n", i, args)
+    }
+    var out strings.Builder
+    if err := s.Serve(strings.NewReader(in.String()), &out); err != nil { t.Fatal(err) }
+    dec := json.NewDecoder(strings.NewReader(out.String()))
+    for _, tt := range tests {
+        var resp struct {
+            Result struct {
+                Content []struct{ Text string } `json:"content"`
+                IsError bool                    `json:"isError"`
+            } `json:"result"`
+        }
+        if err := dec.Decode(&resp); err != nil { t.Fatalf("%s: %v", tt.file, err) }
+        if len(resp.Result.Content) != 1 { t.Fatalf("%s: result %+v", tt.file, resp.Result) }
+        text := resp.Result.Content[0].Text
+        if resp.Result.IsError != tt.isError || !strings.Contains(text, tt.want) { t.Errorf("%s: isError=%v %q, want isError=%v %q", tt.file, resp.Result.IsError, text, tt.isError, tt.want) }
+        if strings.Contains(text, "not context") { t.Errorf("%s: read a file outside the context dir", tt.file) }
+    }
+}
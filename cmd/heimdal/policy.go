@@ -0,0 +1,54 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+
+    "heimdal/internal/config"
+    "heimdal/internal/policy"
+)
+
+const usagePolicy = "usage: heimdal policy test <app> -- <command...>"
+
+func cmdPolicy(cfg *config.Config, args []string) error {
+    if len(args) < 2 || args[0] != "test" { return errors.New(usagePolicy) }
+    app := args[1]
+    rest := args[2:]
+    if len(rest) > 0 && rest[0] == "--" { rest = rest[1:] }
+    if len(rest) == 0 { return errors.New(usagePolicy) }
+
+    def := policy.Allow
+    if app == "shell" {
+        mode := cfg.String("shell_gate")
+        if mode == "off" {
+            fmt.Println("shell_gate is off: heimdal shell does not check commands")
+        } else {
+            def = policy.Action(mode)
+        }
+    }
+    pol, err := commandPolicy(app, def)
+    if err != nil { return err }
+    line := strings.Join(rest, " ")
+    v := pol.Check(line)
+    fmt.Printf("%s: %s\n", v.Action, policy.Normalize(line))
+    if v.Rule > 0 {
+        r := pol.Rules[v.Rule-1]
+        fmt.Printf("  rule %d: %s %s", v.Rule, r.Action, r.Pattern)
+        if r.Reason != "" { fmt.Printf(" (%s)", r.Reason) }
+        fmt.Println()
+    } else {
+        fmt.Printf("  no rule matched; default %s\n", v.Action)
+    }
+    return nil
+}
+
+// commandPolicy compiles policies.commands from app's manifest, if any, with
+// def for commands no rule matches.
+func commandPolicy(app string, def policy.Action) (*policy.Policy, error) {
+    m, path, err := findManifest(app)
+    if err != nil { return nil, err }
+    pol, err := policy.New(m.Policies.Commands, def)
+    if err != nil { return nil, fmt.Errorf("%s: %w", path, err) }
+    return pol, nil
+}
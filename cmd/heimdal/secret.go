@@ -0,0 +1,94 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+
+    "heimdal/internal/config"
+    "heimdal/internal/secret"
+
+    "golang.org/x/term"
+)
+
+const usageSecret = "usage: heimdal secret set <name> [value] | get <name> | ls | rm <name>..."
+
+func cmdSecret(cfg *config.Config, args []string) error {
+    if len(args) == 0 { return errors.New(usageSecret) }
+    store, err := secret.Open(cfg.String("secret_store"))
+    if err != nil { return err }
+    switch args[0] {
+    case "set":
+        if len(args) != 2 && len(args) != 3 { return errors.New("usage: heimdal secret set <name> [value]") }
+        name := args[1]
+        if err := secret.CheckName(name); err != nil { return err }
+        var value string
+        if len(args) == 3 {
+            value = args[2]
+        } else if value, err = readSecretValue(name); err != nil {
+            return err
+        }
+        if err := store.Set(name, value); err != nil { return err }
+        fmt.Printf("stored %s (use %s%s in manifest env)\n", name, secret.RefPrefix, name)
+        return nil
+    case "get":
+        if len(args) != 2 { return errors.New("usage: heimdal secret get <name>") }
+        v, err := store.Get(args[1])
+        if err != nil { return err }
+        fmt.Println(v)
+        return nil
+    case "ls", "list":
+        names, err := store.List()
+        if err != nil { return err }
+        if len(names) == 0 {
+            fmt.Println("no secrets")
+            return nil
+        }
+        for _, n := range names { fmt.Println(n) }
+        return nil
+    case "rm":
+        if len(args) < 2 { return errors.New("usage: heimdal secret rm <name>...") }
+        for _, name := range args[1:] {
+            if err := store.Remove(name); err != nil { return err }
+            fmt.Println("removed:", name)
+        }
+        return nil
+    default:
+        return errors.New(usageSecret)
+    }
+}
+
+// readSecretValue reads a value without echo from the terminal, or the
+// first line of stdin when it is piped, so values stay out of shell history.
+func readSecretValue(name string) (string, error) {
+    fd := int(os.Stdin.Fd())
+    if term.IsTerminal(fd) {
+        fmt.Fprintf(os.Stderr, "value for %s: ", name)
+        b, err := term.ReadPassword(fd)
+        fmt.Fprintln(os.Stderr)
+        if err != nil { return "", err }
+        return string(b), nil
+    }
+    line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+    if err != nil && !errors.Is(err, io.EOF) { return "", err }
+    return strings.TrimRight(line, "\r\n"), nil
+}
+
+// resolveSecret expands a secret:// env value, opening store on first use.
+func resolveSecret(store *secret.Store, backend, key, ref string) (string, error) {
+    name, _ := secret.ParseRef(ref)
+    if *store == nil {
+        s, err := secret.Open(backend)
+        if err != nil { return "", err }
+        *store = s
+    }
+    v, err := (*store).Get(name)
+    if errors.Is(err, secret.ErrNotFound) {
+        err = fmt.Errorf("%w (add it with: heimdal secret set %s)", err, name)
+    }
+    if err != nil { return "", fmt.Errorf("env %s: %w", key, err) }
+    return v, nil
+}
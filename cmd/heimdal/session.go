@@ -0,0 +1,317 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "text/tabwriter"
+    "time"
+    "unicode"
+
+    "heimdal/internal/audit"
+    "heimdal/internal/git"
+    "heimdal/internal/record"
+    "heimdal/internal/sandbox"
+    "heimdal/internal/snapshot"
+    "heimdal/internal/universe"
+
+    "golang.org/x/term"
+)
+
+const usageSession = "usage: heimdal session [ls|show <id>|diff <id>|rollback <id>|replay <id>|rm [--force] <id>...|prune --older-than <age> [--force]]"
+
+func cmdSession(args []string) error {
+    if len(args) == 0 { return errors.New(usageSession) }
+    cwd, _ := os.Getwd()
+    switch args[0] {
+    case "ls", "list":
+        return sessionList(cwd)
+    case "show":
+        if len(args) != 2 { return errors.New("usage: heimdal session show <id>") }
+        return sessionShow(cwd, args[1])
+    case "diff":
+        return sessionDiff(cwd, args[1:])
+    case "rollback":
+        return sessionRollback(cwd, args[1:])
+    case "replay":
+        return sessionReplay(cwd, args[1:])
+    case "rm":
+        force, ids := false, []string{}
+        for _, a := range args[1:] {
+            if a == "--force" || a == "-f" {
+                force = true
+                continue
+            }
+            ids = append(ids, a)
+        }
+        if len(ids) == 0 { return errors.New("usage: heimdal session rm [--force] <id>...") }
+        for _, id := range ids {
+            full, err := universe.RemoveSession(cwd, id, worktreeCleanup(force))
+            if err != nil { return err }
+            fmt.Println("removed:", full)
+        }
+        return nil
+    case "prune":
+        const usagePrune = "usage: heimdal session prune --older-than <age> [--force]"
+        var age string
+        force := false
+        for i := 1; i < len(args); i++ {
+            a := args[i]
+            if a == "--older-than" && i+1 < len(args) {
+                age = args[i+1]
+                i++
+                continue
+            }
+            if strings.HasPrefix(a, "--older-than=") {
+                age = strings.TrimPrefix(a, "--older-than=")
+                continue
+            }
+            if a == "--force" || a == "-f" {
+                force = true
+                continue
+            }
+            return errors.New(usagePrune)
+        }
+        if age == "" { return errors.New(usagePrune) }
+        d, err := parseAge(age)
+        if err != nil { return err }
+        removed, err := universe.PruneSessions(cwd, d, worktreeCleanup(force))
+        for _, id := range removed {
+            fmt.Println("removed:", id)
+        }
+        if err != nil { return err }
+        fmt.Printf("pruned %d session(s)\n", len(removed))
+        return nil
+    default:
+        return errors.New(usageSession)
+    }
+}
+
+func sessionList(cwd string) error {
+    metas, err := universe.ListSessions(cwd)
+    if err != nil { return err }
+    if len(metas) == 0 {
+        fmt.Println("no sessions")
+        return nil
+    }
+    tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(tw, "ID\tSTARTED\tAPP\tWORKDIR\tEXIT")
+    for _, m := range metas {
+        fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", m.ID, m.Started.Local().Format("2006-01-02 15:04"), orDash(m.App), orDash(m.Workdir), exitString(m.ExitCode))
+    }
+    return tw.Flush()
+}
+
+func sessionShow(cwd, id string) error {
+    sess, err := universe.OpenSession(cwd, id)
+    if err != nil { return err }
+    m, err := sess.LoadMeta()
+    if err != nil { return err }
+    fmt.Printf("id:       %s\n", m.ID)
+    fmt.Printf("dir:      %s\n", sess.Dir)
+    fmt.Printf("app:      %s\n", orDash(m.App))
+    fmt.Printf("profile:  %s\n", orDash(m.Profile))
+    fmt.Printf("workdir:  %s\n", orDash(m.Workdir))
+    if m.Worktree != "" { fmt.Printf("worktree: %s (branch %s)\n", m.Worktree, git.WorktreeBranch(m.ID)) }
+    fmt.Printf("started:  %s\n", m.Started.Local().Format(time.RFC3339))
+    if m.Ended != nil {
+        fmt.Printf("ended:    %s (%s)\n", m.Ended.Local().Format(time.RFC3339), m.Ended.Sub(m.Started).Round(time.Second))
+    }
+    var notes []string
+    if m.TimedOut { notes = append(notes, "timed out") }
+    if m.Signal != "" { notes = append(notes, "signal: "+m.Signal) }
+    if len(notes) > 0 {
+        fmt.Printf("exit:     %s (%s)\n", exitString(m.ExitCode), strings.Join(notes, ", "))
+    } else {
+        fmt.Printf("exit:     %s\n", exitString(m.ExitCode))
+    }
+    fmt.Printf("runs:     %d\n", m.Runs)
+    if m.Checkpoint != "" {
+        fmt.Printf("checkpoint: %s (%s)", git.CheckpointRef(m.ID), git.Short(m.Checkpoint))
+        if m.CheckpointRepo != "" { fmt.Printf(" in %s", m.CheckpointRepo) }
+        fmt.Println()
+    }
+    if m.PeakMemory > 0 || m.CPUMS > 0 {
+        fmt.Printf("usage:    peak memory %s, cpu %s\n", sandbox.FormatBytes(m.PeakMemory), time.Duration(m.CPUMS)*time.Millisecond)
+    }
+    if casts, err := record.List(sess.Dir); err == nil && len(casts) > 0 {
+        fmt.Println("recordings:")
+        for _, c := range casts {
+            fmt.Printf("  %s\n", filepath.Base(c))
+        }
+    }
+    if entries, err := os.ReadDir(sess.ContextDir); err == nil {
+        fmt.Println("context:")
+        for _, e := range entries {
+            fmt.Printf("  %s\n", e.Name())
+        }
+    }
+    logPath := filepath.Join(sess.Dir, audit.FileName)
+    if _, err := os.Stat(logPath); err == nil {
+        fmt.Println("audit:")
+        return audit.Tail(logPath, os.Stdout, 0, false, nil)
+    }
+    return nil
+}
+
+func sessionReplay(cwd string, args []string) error {
+    const usageReplay = "usage: heimdal session replay <id> [--speed <x>] [--max-idle <dur>] [--run <n>]"
+    var id string
+    opt := record.ReplayOptions{Speed: 1}
+    run := 0
+    for i := 0; i < len(args); i++ {
+        a := args[i]
+        switch {
+        case a == "--speed" && i+1 < len(args):
+            v, err := strconv.ParseFloat(args[i+1], 64)
+            if err != nil || v <= 0 { return errors.New(usageReplay) }
+            opt.Speed = v
+            i++
+        case a == "--max-idle" && i+1 < len(args):
+            d, err := time.ParseDuration(args[i+1])
+            if err != nil { return errors.New(usageReplay) }
+            opt.MaxIdle = d
+            i++
+        case a == "--run" && i+1 < len(args):
+            n, err := strconv.Atoi(args[i+1])
+            if err != nil || n < 1 { return errors.New(usageReplay) }
+            run = n
+            i++
+        case id == "" && !strings.HasPrefix(a, "-"):
+            id = a
+        default:
+            return errors.New(usageReplay)
+        }
+    }
+    if id == "" { return errors.New(usageReplay) }
+    sess, err := universe.OpenSession(cwd, id)
+    if err != nil { return err }
+    path := record.Path(sess.Dir, run)
+    if run == 0 {
+        casts, err := record.List(sess.Dir)
+        if err != nil { return err }
+        if len(casts) == 0 { return fmt.Errorf("session %s has no recording", sess.ID) }
+        path = casts[len(casts)-1]
+    }
+    return record.Replay(path, os.Stdout, opt)
+}
+
+// sessionDiff prints the files a session changed in its workdir, with
+// unified diffs for text files unless --stat is given.
+func sessionDiff(cwd string, args []string) error {
+    const usageDiff = "usage: heimdal session diff <id> [--stat]"
+    var id string
+    stat := false
+    for _, a := range args {
+        switch {
+        case a == "--stat":
+            stat = true
+        case id == "" && !strings.HasPrefix(a, "-"):
+            id = a
+        default:
+            return errors.New(usageDiff)
+        }
+    }
+    if id == "" { return errors.New(usageDiff) }
+    sess, err := universe.OpenSession(cwd, id)
+    if err != nil { return err }
+    changes, err := sess.Changes()
+    if err != nil { return err }
+    if len(changes) == 0 {
+        fmt.Println("no changes")
+        return nil
+    }
+    for _, c := range changes {
+        fmt.Printf("%c %s\n", unicode.ToUpper(rune(c.Kind[0])), c.Path)
+    }
+    if stat {
+        fmt.Println(changeSummary(changes))
+        return nil
+    }
+    color := term.IsTerminal(int(os.Stdout.Fd())) && os.Getenv("NO_COLOR") == ""
+    store := sess.Store()
+    for _, c := range changes {
+        fmt.Println()
+        if c.Old != nil && c.New != nil && c.Old.Mode != c.New.Mode {
+            fmt.Printf("mode %s: %v -> %v\n", c.Path, c.Old.Mode, c.New.Mode)
+            if c.Old.Hash == c.New.Hash { continue }
+        }
+        if (c.Old != nil && c.Old.Binary) || (c.New != nil && c.New.Binary) {
+            fmt.Printf("Binary file %s differs\n", c.Path)
+            continue
+        }
+        old, err := store.Get(c.Old)
+        if err == nil {
+            var cur []byte
+            if cur, err = store.Get(c.New); err == nil {
+                aName, bName := "a/"+c.Path, "b/"+c.Path
+                if c.Old == nil { aName = "/dev/null" }
+                if c.New == nil { bName = "/dev/null" }
+                printDiff(snapshot.Unified(aName, bName, old, cur), color)
+                continue
+            }
+        }
+        fmt.Printf("%s %s: %v\n", c.Kind, c.Path, err)
+    }
+    return nil
+}
+
+// printDiff writes a unified diff, colored like git's when color is set.
+func printDiff(diff string, color bool) {
+    if !color {
+        fmt.Print(diff)
+        return
+    }
+    for _, line := range strings.SplitAfter(diff, "\n") {
+        switch {
+        case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+            fmt.Print("\x1b[1m" + strings.TrimSuffix(line, "\n") + "\x1b[0m\n")
+        case strings.HasPrefix(line, "@@"):
+            fmt.Print("\x1b[36m" + strings.TrimSuffix(line, "\n") + "\x1b[0m\n")
+        case strings.HasPrefix(line, "+"):
+            fmt.Print("\x1b[32m" + strings.TrimSuffix(line, "\n") + "\x1b[0m\n")
+        case strings.HasPrefix(line, "-"):
+            fmt.Print("\x1b[31m" + strings.TrimSuffix(line, "\n") + "\x1b[0m\n")
+        default:
+            fmt.Print(line)
+        }
+    }
+}
+
+// changeSummary counts changes by kind, e.g. "3 files changed (1 added, 2 modified)".
+func changeSummary(changes []snapshot.Change) string {
+    counts := map[string]int{}
+    for _, c := range changes { counts[c.Kind]++ }
+    var parts []string
+    for _, k := range []string{snapshot.Added, snapshot.Modified, snapshot.Deleted} {
+        if counts[k] > 0 { parts = append(parts, fmt.Sprintf("%d %s", counts[k], k)) }
+    }
+    noun := "files"
+    if len(changes) == 1 { noun = "file" }
+    return fmt.Sprintf("%d %s changed (%s)", len(changes), noun, strings.Join(parts, ", "))
+}
+
+// parseAge parses durations like 7d, 12h or 90m.
+func parseAge(s string) (time.Duration, error) {
+    if strings.HasSuffix(s, "d") {
+        n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+        if err != nil || n < 0 { return 0, fmt.Errorf("invalid age %q", s) }
+        return time.Duration(n) * 24 * time.Hour, nil
+    }
+    d, err := time.ParseDuration(s)
+    if err != nil || d < 0 { return 0, fmt.Errorf("invalid age %q (use e.g. 7d, 12h)", s) }
+    return d, nil
+}
+
+func exitString(code *int) string {
+    if code == nil { return "-" }
+    return strconv.Itoa(*code)
+}
+
+func orDash(s string) string {
+    if s == "" { return "-" }
+    return s
+}
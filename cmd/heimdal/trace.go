@@ -0,0 +1,29 @@
+package main
+
+import (
+    "os"
+
+    "heimdal/internal/audit"
+    "heimdal/internal/policy"
+    "heimdal/internal/sandbox"
+    "heimdal/internal/trace"
+)
+
+// startExecTrace logs every program run under pid and applies the app's
+// command rules to it. Tracing sees execs after the fact, so a denied
+// process is killed once spotted and ask rules are only recorded.
+func startExecTrace(pid int, alog *audit.Logger, app string, pol *policy.Policy) (*trace.Tracer, error) {
+    return trace.Start(pid, trace.DefaultInterval, func(e trace.Exec) {
+        if len(e.Argv) > 1 && e.Argv[1] == sandbox.InitArg { return } // restricted profile's init stage
+        ev := audit.Event{Time: e.Time.UTC(), Kind: audit.KindProcExec, App: app, PID: e.PID, PPID: e.PPID,
+            Exe: e.Exe, Argv: e.Argv, Workdir: e.Cwd}
+        if v := pol.CheckArgv(e.Argv); v.Rule > 0 {
+            ev.Decision, ev.Reason = string(v.Action), v.Reason
+            if v.Action == policy.Deny {
+                if p, err := os.FindProcess(e.PID); err == nil { _ = p.Kill() }
+                ev.Reason += " (killed)"
+            }
+        }
+        _ = alog.Log(ev)
+    })
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "heimdal/internal/git"
+    "heimdal/internal/record"
+    "heimdal/internal/universe"
+
+    "golang.org/x/term"
+)
+
+// worktreeRun is a run isolated in a linked git worktree, on a branch of
+// its own, so several apps can work on one repository at once.
+type worktreeRun struct {
+    repo   *git.Repo // the repository heimdal was started in
+    tree   *git.Repo // the session's worktree
+    branch string
+}
+
+// openWorktree creates the session's worktree under its session dir, or
+// reuses the one a resumed session already has, and points meta.Worktree
+// at the directory matching cwd inside it.
+func openWorktree(sess universe.Session, meta *universe.Meta, cwd string) (*worktreeRun, error) {
+    repo, err := git.Open(cwd)
+    if errors.Is(err, git.ErrNotRepo) { return nil, fmt.Errorf("%s is not in a git repository", cwd) }
+    if err != nil { return nil, err }
+    w := &worktreeRun{repo: repo, branch: git.WorktreeBranch(sess.ID)}
+    path := filepath.Join(sess.Dir, "worktree")
+    if _, err := os.Stat(path); err == nil {
+        w.tree, err = git.Open(path)
+        if err != nil { return nil, fmt.Errorf("%s: %w", path, err) }
+    } else {
+        if w.tree, err = repo.AddWorktree(path, w.branch); err != nil { return nil, err }
+    }
+    dir := filepath.Join(w.tree.Root, repo.Rel(cwd))
+    if err := os.MkdirAll(dir, 0o755); err != nil { return nil, err }
+    meta.Worktree, meta.WorktreeRepo = dir, repo.Root
+    if err := sess.SaveMeta(*meta); err != nil { return nil, err }
+    fmt.Fprintf(os.Stderr, "[heimdal] worktree %s on branch %s\n", w.tree.Root, w.branch)
+    return w, nil
+}
+
+// finish offers to merge the worktree's branch into the original checkout,
+// keep it, or discard it. A worktree without changes is removed; without a
+// terminal to ask on, it is kept.
+func (w *worktreeRun) finish(sess universe.Session, app string) {
+    dirty, err := w.tree.Dirty()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "[heimdal] worktree: %v\n", err)
+        return
+    }
+    ahead, err := w.repo.Ahead(w.branch)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "[heimdal] worktree: %v\n", err)
+        return
+    }
+    if !dirty && ahead == 0 {
+        if err := w.discard(sess); err != nil {
+            fmt.Fprintf(os.Stderr, "[heimdal] worktree: %v\n", err)
+            return
+        }
+        fmt.Fprintln(os.Stderr, "[heimdal] worktree unchanged; removed")
+        return
+    }
+
+    into := w.repo.CurrentBranch()
+    if into == "" { into = "HEAD" }
+    var state []string
+    if ahead > 0 { state = append(state, fmt.Sprintf("%d commit(s)", ahead)) }
+    if dirty { state = append(state, "uncommitted changes") }
+    choice := "k"
+    if term.IsTerminal(int(os.Stdin.Fd())) {
+        fmt.Fprintf(os.Stderr, "[heimdal] branch %s has %s. [m]erge into %s, [k]eep, [d]iscard? [k] ",
+            w.branch, strings.Join(state, " and "), into)
+        line, _ := bufio.NewReader(record.Stdin).ReadString('\n')
+        if a := strings.ToLower(strings.TrimSpace(line)); a != "" { choice = a[:1] }
+    }
+
+    switch choice {
+    case "m":
+        err := w.merge(sess, app)
+        if err == nil {
+            fmt.Fprintf(os.Stderr, "[heimdal] merged %s into %s\n", w.branch, into)
+            return
+        }
+        fmt.Fprintf(os.Stderr, "[heimdal] merge failed: %v\n", err)
+    case "d":
+        if err := w.discard(sess); err != nil {
+            fmt.Fprintf(os.Stderr, "[heimdal] worktree: %v\n", err)
+            return
+        }
+        fmt.Fprintf(os.Stderr, "[heimdal] discarded %s\n", w.branch)
+        return
+    }
+    fmt.Fprintf(os.Stderr, "[heimdal] kept worktree %s on %s; resume with `heimdal run --session %s %s`\n",
+        w.tree.Root, w.branch, sess.ID, app)
+}
+
+// merge commits what the app left uncommitted and merges the branch into
+// the original checkout, removing the worktree once it is in.
+func (w *worktreeRun) merge(sess universe.Session, app string) error {
+    if dirty, _ := w.tree.Dirty(); dirty {
+        if err := w.tree.CommitAll("heimdal: " + app + " in session " + sess.ID); err != nil { return err }
+    }
+    if err := w.repo.Merge(w.branch, "Merge heimdal session "+sess.ID+" ("+app+")"); err != nil { return err }
+    return w.discard(sess)
+}
+
+// discard removes the worktree and its branch.
+func (w *worktreeRun) discard(sess universe.Session) error {
+    if err := w.repo.RemoveWorktree(w.tree.Root); err != nil { return err }
+    if err := w.repo.DeleteBranch(w.branch); err != nil { return err }
+    m, err := sess.LoadMeta()
+    if err != nil { return err }
+    if m.Worktree != "" { _ = sess.DropBaseline(m.Worktree) }
+    m.Worktree, m.WorktreeRepo = "", ""
+    return sess.SaveMeta(m)
+}
+
+// worktreeCleanup returns the cleanup `session rm` and `session prune` run
+// before deleting a session dir: it removes the session's worktree and
+// branch from the repository, as discard does. Unless force is set, it
+// refuses while the branch has commits HEAD lacks or the worktree has
+// uncommitted changes.
+func worktreeCleanup(force bool) func(universe.Session, universe.Meta) error {
+    return func(sess universe.Session, m universe.Meta) error {
+        if m.Worktree == "" { return nil }
+        root := m.WorktreeRepo
+        if root == "" { root = m.Workdir } // sessions from before WorktreeRepo
+        repo, err := git.Open(root)
+        if err != nil { return fmt.Errorf("session %s: worktree repository %s: %w", sess.ID, root, err) }
+        path := filepath.Join(sess.Dir, "worktree")
+        branch := git.WorktreeBranch(sess.ID)
+        _, err = repo.Resolve("refs/heads/" + branch)
+        hasBranch := err == nil
+        if !force {
+            var state []string
+            if hasBranch {
+                if n, err := repo.Ahead(branch); err == nil && n > 0 { state = append(state, fmt.Sprintf("%d unmerged commit(s)", n)) }
+            }
+            if tree, err := git.Open(path); err == nil {
+                if dirty, _ := tree.Dirty(); dirty { state = append(state, "uncommitted changes") }
+            }
+            if len(state) > 0 {
+                return fmt.Errorf("session %s: branch %s has %s; merge it with `heimdal run --session %s %s`, or pass --force to discard it",
+                    sess.ID, branch, strings.Join(state, " and "), sess.ID, m.App)
+            }
+        }
+        if _, err := os.Stat(path); err == nil {
+            if err := repo.RemoveWorktree(path); err != nil { return fmt.Errorf("session %s: %w", sess.ID, err) }
+        } else if err := repo.PruneWorktrees(); err != nil {
+            return fmt.Errorf("session %s: %w", sess.ID, err)
+        }
+        if hasBranch {
+            if err := repo.DeleteBranch(branch); err != nil { return fmt.Errorf("session %s: %w", sess.ID, err) }
+        }
+        return nil
+    }
+}
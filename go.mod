@@ -2,3 +2,10 @@ module heimdal
 
 go 1.21
 
+require (
+	github.com/creack/pty v1.1.24
+	golang.org/x/crypto v0.33.0
+	golang.org/x/sys v0.30.0
+	golang.org/x/term v0.29.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,121 @@
+package adapter
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Context is the Heimdal session an app is launched into.
+type Context struct {
+    SessionID  string
+    ContextDir string
+    Workdir    string
+    // MCPConfig is the path of context/mcp.json, or "" when the app did not
+    // opt in to the MCP server.
+    MCPConfig string
+}
+
+// Plan is what an adapter adds to a launch: args inserted before the user's
+// args and extra env vars.
+type Plan struct {
+    Args []string
+    Env  map[string]string
+}
+
+// Adapter translates the session context into a CLI's native mechanism.
+type Adapter interface {
+    Name() string
+    // Version returns the installed version of cmd, e.g. "1.0.43".
+    Version(cmd string) (string, error)
+    // Prepare plans the launch for args (manifest args followed by the user's).
+    // It must leave subcommand invocations such as `claude mcp list` alone.
+    Prepare(c Context, args []string) (Plan, error)
+    // ExitReason explains a non-zero exit code, or returns "" if unknown.
+    ExitReason(code int) string
+}
+
+var adapters = map[string]Adapter{
+    "claude": Claude{},
+    "gemini": Gemini{},
+}
+
+// Names lists the built-in adapters.
+func Names() []string {
+    out := make([]string, 0, len(adapters))
+    for n := range adapters { out = append(out, n) }
+    sort.Strings(out)
+    return out
+}
+
+// Resolve picks the adapter for a manifest: name selects one explicitly
+// ("none" disables adapters), otherwise cmd's base name is matched. A nil
+// Adapter means the app is launched as-is.
+func Resolve(name, cmd string) (Adapter, error) {
+    switch name {
+    case "none":
+        return nil, nil
+    case "":
+        base := strings.TrimSuffix(filepath.Base(cmd), ".exe")
+        return adapters[base], nil
+    }
+    a, ok := adapters[name]
+    if !ok { return nil, fmt.Errorf("unknown adapter %q (want %s|none)", name, strings.Join(Names(), "|")) }
+    return a, nil
+}
+
+var versionRe = regexp.MustCompile(`\d+\.\d+(\.\d+)?([-+][0-9A-Za-z.-]+)?`)
+
+// commandVersion runs `cmd --version` and extracts the first version number.
+func commandVersion(cmd string) (string, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    c := exec.CommandContext(ctx, cmd, "--version")
+    out, err := c.Output()
+    if err != nil { return "", fmt.Errorf("%s --version: %w", cmd, err) }
+    v := versionRe.FindString(string(out))
+    if v == "" { return "", fmt.Errorf("%s --version: no version in %q", cmd, strings.TrimSpace(string(out))) }
+    return v, nil
+}
+
+// isSubcommand reports whether args start with one of subs; these CLIs only
+// accept a subcommand as their first argument.
+func isSubcommand(args []string, subs ...string) bool {
+    if len(args) == 0 { return false }
+    for _, s := range subs {
+        if args[0] == s { return true }
+    }
+    return false
+}
+
+// contextFiles lists the files in dir, for telling the app what is there.
+func contextFiles(dir string) []string {
+    entries, err := os.ReadDir(dir)
+    if err != nil { return nil }
+    var out []string
+    for _, e := range entries {
+        if !e.IsDir() { out = append(out, e.Name()) }
+    }
+    return out
+}
+
+// systemPrompt is the short note appended to the app's system prompt.
+func systemPrompt(c Context) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "You are running inside a Heimdal universe session (%s). ", c.SessionID)
+    fmt.Fprintf(&b, "Session context files are in %s", c.ContextDir)
+    if files := contextFiles(c.ContextDir); len(files) > 0 {
+        fmt.Fprintf(&b, " (%s)", strings.Join(files, ", "))
+    }
+    b.WriteString("; read them when you need background on this repository.")
+    if c.MCPConfig != "" {
+        b.WriteString(" The heimdal MCP server offers wiki_search, wiki_show, session_context and repo_files.")
+    }
+    return b.String()
+}
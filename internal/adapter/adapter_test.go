@@ -0,0 +1,193 @@
+package adapter
+
+import (
+    "encoding/json"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "testing"
+)
+
+// fakeCLI puts a shell script called name on PATH. It prints version for
+// --version and otherwise writes its argv, one per line, and
+// GEMINI_CLI_SYSTEM_SETTINGS_PATH to $FAKE_OUT.
+func fakeCLI(t *testing.T, name, version string) {
+    t.Helper()
+    if runtime.GOOS == "windows" { t.Skip("fake CLIs are shell scripts") }
+    dir := t.TempDir()
+    script := "#!/bin/sh\n" +
+        "if [ \"$1\" = --version ]; then echo '" + version + "'; exit 0; fi\n" +
+        "for a in \"$@\"; do printf '%s\\n' \"$a\"; done > \"$FAKE_OUT\"\n" +
+        "printf 'settings=%s\\n' \"$GEMINI_CLI_SYSTEM_SETTINGS_PATH\" >> \"$FAKE_OUT\"\n"
+    if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil { t.Fatal(err) }
+    t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// launch runs name with the plan applied the way cmdRun does: plan args
+// before the user's, plan env on top of the host's. It returns what the
+// fake CLI saw.
+func launch(t *testing.T, name string, p Plan, args []string) []string {
+    t.Helper()
+    out := filepath.Join(t.TempDir(), "argv")
+    cmd := exec.Command(name, append(p.Args, args...)...)
+    cmd.Env = append(os.Environ(), "FAKE_OUT="+out)
+    for k, v := range p.Env { cmd.Env = append(cmd.Env, k+"="+v) }
+    if b, err := cmd.CombinedOutput(); err != nil { t.Fatalf("%s: %v\n%s", name, err, b) }
+    b, err := os.ReadFile(out)
+    if err != nil { t.Fatal(err) }
+    return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+// sessionContext returns a Context with a context dir holding one file and,
+// if mcp is set, an mcp.json.
+func sessionContext(t *testing.T, mcp bool) Context {
+    t.Helper()
+    dir := t.TempDir()
+    if err := os.WriteFile(filepath.Join(dir, "system.md"), []byte("# system\n"), 0o644); err != nil { t.Fatal(err) }
+    c := Context{SessionID: "0123456789abcdef", ContextDir: dir, Workdir: t.TempDir()}
+    if mcp {
+        c.MCPConfig = filepath.Join(dir, "mcp.json")
+        conf := `{"mcpServers":{"heimdal":{"command":"heimdal","args":["mcp","serve"]}}}`
+        if err := os.WriteFile(c.MCPConfig, []byte(conf), 0o644); err != nil { t.Fatal(err) }
+    }
+    return c
+}
+
+func indexOf(list []string, s string) int {
+    for i, v := range list {
+        if v == s { return i }
+    }
+    return -1
+}
+
+func TestResolve(t *testing.T) {
+    tests := []struct {
+        name, cmd, want string
+        wantErr         bool
+    }{
+        {"", "claude", "claude", false},
+        {"", "/usr/local/bin/gemini", "gemini", false},
+        {"", "claude.exe", "claude", false},
+        {"", "aider", "", false},
+        {"none", "claude", "", false},
+        {"gemini", "my-gemini-wrapper", "gemini", false},
+        {"cursor", "cursor", "", true},
+    }
+    for _, tt := range tests {
+        a, err := Resolve(tt.name, tt.cmd)
+        if err != nil {
+            if !tt.wantErr { t.Errorf("Resolve(%q, %q): %v", tt.name, tt.cmd, err) }
+            continue
+        }
+        if tt.wantErr {
+            t.Errorf("Resolve(%q, %q): want error", tt.name, tt.cmd)
+            continue
+        }
+        got := ""
+        if a != nil { got = a.Name() }
+        if got != tt.want { t.Errorf("Resolve(%q, %q) = %q, want %q", tt.name, tt.cmd, got, tt.want) }
+    }
+}
+
+func TestVersion(t *testing.T) {
+    fakeCLI(t, "claude", "1.0.43 (Claude Code)")
+    fakeCLI(t, "gemini", "gemini-cli v0.1.9-nightly.250701")
+    if v, err := (Claude{}).Version("claude"); err != nil || v != "1.0.43" { t.Errorf("claude version = %q, %v", v, err) }
+    if v, err := (Gemini{}).Version("gemini"); err != nil || v != "0.1.9-nightly.250701" { t.Errorf("gemini version = %q, %v", v, err) }
+
+    fakeCLI(t, "claude", "unknown")
+    if v, err := (Claude{}).Version("claude"); err == nil { t.Errorf("version without a number = %q, want error", v) }
+    if _, err := (Claude{}).Version(filepath.Join(t.TempDir(), "missing")); err == nil { t.Error("missing binary: want error") }
+}
+
+func TestClaudePrepare(t *testing.T) {
+    fakeCLI(t, "claude", "1.0.0")
+    c := sessionContext(t, true)
+    p, err := Claude{}.Prepare(c, []string{"-p", "hi"})
+    if err != nil { t.Fatal(err) }
+    argv := launch(t, "claude", p, []string{"-p", "hi"})
+
+    if i := indexOf(argv, "--add-dir"); i < 0 || argv[i+1] != c.ContextDir { t.Errorf("--add-dir %s missing: %q", c.ContextDir, argv) }
+    if i := indexOf(argv, "--mcp-config"); i < 0 || argv[i+1] != c.MCPConfig { t.Errorf("--mcp-config %s missing: %q", c.MCPConfig, argv) }
+    i := indexOf(argv, "--append-system-prompt")
+    if i < 0 {
+        t.Fatalf("--append-system-prompt missing: %q", argv)
+    }
+    prompt := argv[i+1]
+    for _, want := range []string{c.SessionID, c.ContextDir, "system.md", "wiki_search"} {
+        if !strings.Contains(prompt, want) { t.Errorf("system prompt lacks %q: %s", want, prompt) }
+    }
+    // The user's args come last, unchanged.
+    if n := len(argv); n < 3 || argv[n-3] != "-p" || argv[n-2] != "hi" { t.Errorf("user args not at the end: %q", argv) }
+
+    // Without MCP, no --mcp-config and no mention of the tools.
+    p, err = Claude{}.Prepare(sessionContext(t, false), nil)
+    if err != nil { t.Fatal(err) }
+    if indexOf(p.Args, "--mcp-config") >= 0 || strings.Contains(strings.Join(p.Args, " "), "wiki_search") { t.Errorf("MCP args without MCP: %q", p.Args) }
+}
+
+func TestGeminiPrepare(t *testing.T) {
+    fakeCLI(t, "gemini", "0.1.9")
+    c := sessionContext(t, true)
+    p, err := Gemini{}.Prepare(c, []string{"--model", "pro"})
+    if err != nil { t.Fatal(err) }
+    argv := launch(t, "gemini", p, []string{"--model", "pro"})
+
+    if i := indexOf(argv, "--include-directories"); i < 0 || argv[i+1] != c.ContextDir { t.Errorf("--include-directories %s missing: %q", c.ContextDir, argv) }
+    settings := filepath.Join(c.ContextDir, geminiSettingsFile)
+    if argv[len(argv)-1] != "settings="+settings { t.Errorf("GEMINI_CLI_SYSTEM_SETTINGS_PATH not passed: %q", argv) }
+    b, err := os.ReadFile(settings)
+    if err != nil { t.Fatal(err) }
+    var conf struct {
+        MCPServers map[string]json.RawMessage `json:"mcpServers"`
+    }
+    if err := json.Unmarshal(b, &conf); err != nil { t.Fatal(err) }
+    if _, ok := conf.MCPServers["heimdal"]; !ok { t.Errorf("settings lack the heimdal server: %s", b) }
+
+    p, err = Gemini{}.Prepare(sessionContext(t, false), nil)
+    if err != nil { t.Fatal(err) }
+    if len(p.Env) != 0 { t.Errorf("env without MCP: %v", p.Env) }
+}
+
+func TestSubcommandPassthrough(t *testing.T) {
+    c := sessionContext(t, true)
+    tests := []struct {
+        a    Adapter
+        args []string
+    }{
+        {Claude{}, []string{"mcp", "list"}},
+        {Claude{}, []string{"config", "get", "theme"}},
+        {Claude{}, []string{"doctor"}},
+        {Gemini{}, []string{"mcp", "list"}},
+        {Gemini{}, []string{"extensions", "list"}},
+    }
+    for _, tt := range tests {
+        p, err := tt.a.Prepare(c, tt.args)
+        if err != nil { t.Fatal(err) }
+        if len(p.Args) != 0 || len(p.Env) != 0 { t.Errorf("%s %q: injected %q %v", tt.a.Name(), tt.args, p.Args, p.Env) }
+    }
+    // A subcommand name later in argv is just a prompt word.
+    p, err := Claude{}.Prepare(c, []string{"-p", "mcp"})
+    if err != nil { t.Fatal(err) }
+    if len(p.Args) == 0 { t.Error("claude -p mcp: flags not injected") }
+}
+
+func TestExitReason(t *testing.T) {
+    tests := []struct {
+        a    Adapter
+        code int
+        want string
+    }{
+        {Claude{}, 1, "claude reported an error"},
+        {Claude{}, 130, "interrupted"},
+        {Claude{}, 2, ""},
+        {Gemini{}, 41, "authentication failed"},
+        {Gemini{}, 53, "session turn limit reached"},
+        {Gemini{}, 0, ""},
+    }
+    for _, tt := range tests {
+        if got := tt.a.ExitReason(tt.code); got != tt.want { t.Errorf("%s.ExitReason(%d) = %q, want %q", tt.a.Name(), tt.code, got, tt.want) }
+    }
+}
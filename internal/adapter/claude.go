@@ -0,0 +1,33 @@
+package adapter
+
+// Claude adapts Anthropic's Claude Code CLI (`claude`).
+type Claude struct{}
+
+func (Claude) Name() string { return "claude" }
+
+func (Claude) Version(cmd string) (string, error) { return commandVersion(cmd) }
+
+// claudeSubcommands take their own flags and no prompt.
+var claudeSubcommands = []string{"config", "mcp", "migrate-installer", "setup-token", "doctor", "update", "install"}
+
+// Prepare gives Claude read access to the context dir, appends a note about
+// it to the system prompt and registers the MCP server when enabled.
+func (Claude) Prepare(c Context, args []string) (Plan, error) {
+    if isSubcommand(args, claudeSubcommands...) { return Plan{}, nil }
+    p := Plan{Args: []string{"--add-dir", c.ContextDir, "--append-system-prompt", systemPrompt(c)}}
+    if c.MCPConfig != "" {
+        p.Args = append(p.Args, "--mcp-config", c.MCPConfig)
+    }
+    return p, nil
+}
+
+// Claude exits 1 for any failure and 130 when interrupted.
+func (Claude) ExitReason(code int) string {
+    switch code {
+    case 1:
+        return "claude reported an error"
+    case 130:
+        return "interrupted"
+    }
+    return ""
+}
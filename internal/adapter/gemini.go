@@ -0,0 +1,52 @@
+package adapter
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+)
+
+// Gemini adapts Google's Gemini CLI (`gemini`).
+type Gemini struct{}
+
+func (Gemini) Name() string { return "gemini" }
+
+func (Gemini) Version(cmd string) (string, error) { return commandVersion(cmd) }
+
+var geminiSubcommands = []string{"mcp", "extensions"}
+
+// geminiSettingsFile is written to the context dir when MCP is enabled.
+const geminiSettingsFile = "gemini-settings.json"
+
+// Prepare adds the context dir to Gemini's workspace and points its system
+// settings at a file registering the MCP server, since Gemini has no flag
+// for an MCP config.
+func (Gemini) Prepare(c Context, args []string) (Plan, error) {
+    if isSubcommand(args, geminiSubcommands...) { return Plan{}, nil }
+    p := Plan{Args: []string{"--include-directories", c.ContextDir}}
+    if c.MCPConfig != "" {
+        b, err := os.ReadFile(c.MCPConfig)
+        if err != nil { return Plan{}, err }
+        var conf map[string]json.RawMessage
+        if err := json.Unmarshal(b, &conf); err != nil { return Plan{}, err }
+        out, err := json.MarshalIndent(map[string]json.RawMessage{"mcpServers": conf["mcpServers"]}, "", "  ")
+        if err != nil { return Plan{}, err }
+        path := filepath.Join(c.ContextDir, geminiSettingsFile)
+        if err := os.WriteFile(path, append(out, '\n'), 0o644); err != nil { return Plan{}, err }
+        p.Env = map[string]string{"GEMINI_CLI_SYSTEM_SETTINGS_PATH": path}
+    }
+    return p, nil
+}
+
+// Gemini CLI exit codes, from its fatal error types.
+var geminiExits = map[int]string{
+    1:   "gemini reported an error",
+    41:  "authentication failed",
+    42:  "invalid input",
+    44:  "sandbox error",
+    52:  "invalid configuration",
+    53:  "session turn limit reached",
+    130: "interrupted",
+}
+
+func (Gemini) ExitReason(code int) string { return geminiExits[code] }
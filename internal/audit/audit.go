@@ -0,0 +1,233 @@
+package audit
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "heimdal/internal/redact"
+)
+
+// FileName is the name of the per-session audit log inside the session dir.
+const FileName = "audit.jsonl"
+
+// Event kinds written by the CLI.
+const (
+    KindSessionStart = "session_start"
+    KindManifest     = "manifest"
+    KindExec         = "exec"
+    KindExit         = "exit"
+    KindCommand      = "command"   // a command typed in heimdal shell
+    KindProcExec     = "proc_exec" // a program run by a wrapped app or its children
+)
+
+// ManifestInfo is the resolved manifest as recorded in the audit log.
+// Only env keys are recorded, never values.
+type ManifestInfo struct {
+    Name    string   `json:"name"`
+    Cmd     string   `json:"cmd"`
+    Args    []string `json:"args,omitempty"`
+    EnvKeys []string `json:"env_keys,omitempty"`
+    Path    string   `json:"path,omitempty"`
+}
+
+// Event is a single JSONL record in audit.jsonl.
+type Event struct {
+    Time       time.Time     `json:"time"`
+    Session    string        `json:"session"`
+    Kind       string        `json:"kind"`
+    App        string        `json:"app,omitempty"`
+    Profile    string        `json:"profile,omitempty"`
+    Workdir    string        `json:"workdir,omitempty"`
+    Manifest   *ManifestInfo `json:"manifest,omitempty"`
+    Argv       []string      `json:"argv,omitempty"`
+    PID        int           `json:"pid,omitempty"`
+    PPID       int           `json:"ppid,omitempty"`
+    Exe        string        `json:"exe,omitempty"`
+    EnvKeys    []string      `json:"env_keys,omitempty"`
+    Isolation  []string      `json:"isolation,omitempty"`
+    Adapter    string        `json:"adapter,omitempty"`
+    AppVersion string        `json:"app_version,omitempty"`
+    ExitCode   *int          `json:"exit_code,omitempty"`
+    Signal     string        `json:"signal,omitempty"`
+    Command    string        `json:"command,omitempty"`
+    Decision   string        `json:"decision,omitempty"` // allow|deny for a command
+    Reason     string        `json:"reason,omitempty"`   // why: adapter's reading of ExitCode, or the Decision's cause
+    DurationMS int64         `json:"duration_ms,omitempty"`
+    PeakMemory int64         `json:"peak_memory,omitempty"` // bytes, on exit
+    CPUMS      int64         `json:"cpu_ms,omitempty"`      // user+system CPU time, on exit
+    Error      string        `json:"error,omitempty"`
+}
+
+// Logger appends events to a session's audit.jsonl.
+type Logger struct {
+    mu      sync.Mutex
+    f       *os.File
+    session string
+    red     *redact.Redactor
+}
+
+// Open opens (or creates) the audit log in sessionDir for appending.
+func Open(sessionDir, sessionID string) (*Logger, error) {
+    if err := os.MkdirAll(sessionDir, 0o755); err != nil { return nil, err }
+    f, err := os.OpenFile(filepath.Join(sessionDir, FileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+    if err != nil { return nil, err }
+    return &Logger{f: f, session: sessionID}, nil
+}
+
+// Redact masks secrets known to r in every event logged from now on.
+func (l *Logger) Redact(r *redact.Redactor) {
+    if l == nil { return }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.red = r
+}
+
+// Log writes one event. Time and Session are filled in when empty.
+func (l *Logger) Log(ev Event) error {
+    if l == nil { return nil }
+    if ev.Time.IsZero() { ev.Time = time.Now().UTC() }
+    if ev.Session == "" { ev.Session = l.session }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    b, err := json.Marshal(redactEvent(ev, l.red))
+    if err != nil { return err }
+    _, err = l.f.Write(append(b, '\n'))
+    return err
+}
+
+// redactEvent masks secrets in the free-form strings of ev. Fields heimdal
+// fills in itself (session, kind, env keys, decision) are left alone, so
+// the log keeps its structure whatever gets masked.
+func redactEvent(ev Event, r *redact.Redactor) Event {
+    if r == nil { return ev }
+    list := func(in []string) []string {
+        if in == nil { return nil }
+        out := make([]string, len(in))
+        for i, s := range in { out[i] = r.String(s) }
+        return out
+    }
+    ev.App, ev.Profile, ev.Workdir = r.String(ev.App), r.String(ev.Profile), r.String(ev.Workdir)
+    if ev.Manifest != nil {
+        m := *ev.Manifest
+        m.Name, m.Cmd, m.Path, m.Args = r.String(m.Name), r.String(m.Cmd), r.String(m.Path), list(m.Args)
+        ev.Manifest = &m
+    }
+    ev.Argv, ev.Exe, ev.Isolation = list(ev.Argv), r.String(ev.Exe), list(ev.Isolation)
+    ev.AppVersion, ev.Signal, ev.Command = r.String(ev.AppVersion), r.String(ev.Signal), r.String(ev.Command)
+    ev.Reason, ev.Error = r.String(ev.Reason), r.String(ev.Error)
+    return ev
+}
+
+// Close closes the underlying file.
+func (l *Logger) Close() error {
+    if l == nil { return nil }
+    return l.f.Close()
+}
+
+// SortedKeys returns the keys of m in sorted order, for recording env keys.
+func SortedKeys(m map[string]string) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m { keys = append(keys, k) }
+    sort.Strings(keys)
+    return keys
+}
+
+// Format renders an event as a single human-readable line.
+func Format(ev Event) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "%s %s %-13s", ev.Time.Local().Format("2006-01-02 15:04:05"), shortID(ev.Session), ev.Kind)
+    if ev.App != "" { fmt.Fprintf(&b, " app=%s", ev.App) }
+    if ev.Profile != "" { fmt.Fprintf(&b, " profile=%s", ev.Profile) }
+    if ev.Workdir != "" { fmt.Fprintf(&b, " workdir=%s", ev.Workdir) }
+    if m := ev.Manifest; m != nil {
+        fmt.Fprintf(&b, " manifest=%s cmd=%s", m.Name, m.Cmd)
+        if m.Path != "" { fmt.Fprintf(&b, " path=%s", m.Path) }
+        if len(m.Args) > 0 { fmt.Fprintf(&b, " args=%q", m.Args) }
+    }
+    if ev.PID != 0 { fmt.Fprintf(&b, " pid=%d ppid=%d", ev.PID, ev.PPID) }
+    if ev.Exe != "" { fmt.Fprintf(&b, " exe=%s", ev.Exe) }
+    if len(ev.Argv) > 0 { fmt.Fprintf(&b, " argv=%q", ev.Argv) }
+    if len(ev.EnvKeys) > 0 { fmt.Fprintf(&b, " env=%s", strings.Join(ev.EnvKeys, ",")) }
+    if len(ev.Isolation) > 0 { fmt.Fprintf(&b, " isolation=%s", strings.Join(ev.Isolation, ",")) }
+    if ev.Adapter != "" { fmt.Fprintf(&b, " adapter=%s", ev.Adapter) }
+    if ev.AppVersion != "" { fmt.Fprintf(&b, " version=%s", ev.AppVersion) }
+    if ev.ExitCode != nil { fmt.Fprintf(&b, " exit=%d", *ev.ExitCode) }
+    if ev.Signal != "" { fmt.Fprintf(&b, " signal=%q", ev.Signal) }
+    if ev.Command != "" { fmt.Fprintf(&b, " command=%q", ev.Command) }
+    if ev.Decision != "" { fmt.Fprintf(&b, " decision=%s", ev.Decision) }
+    if ev.Reason != "" { fmt.Fprintf(&b, " reason=%q", ev.Reason) }
+    if ev.DurationMS > 0 { fmt.Fprintf(&b, " duration=%s", time.Duration(ev.DurationMS)*time.Millisecond) }
+    if ev.PeakMemory > 0 { fmt.Fprintf(&b, " peak_memory=%dKiB", ev.PeakMemory/1024) }
+    if ev.CPUMS > 0 { fmt.Fprintf(&b, " cpu=%s", time.Duration(ev.CPUMS)*time.Millisecond) }
+    if ev.Error != "" { fmt.Fprintf(&b, " error=%q", ev.Error) }
+    return b.String()
+}
+
+func shortID(id string) string {
+    if len(id) > 8 { return id[:8] }
+    return id
+}
+
+// Tail writes the last n events of the log at path to w (all when n <= 0).
+// With follow, it keeps polling for appended events until stop is closed.
+func Tail(path string, w io.Writer, n int, follow bool, stop <-chan struct{}) error {
+    f, err := os.Open(path)
+    if err != nil { return err }
+    defer f.Close()
+
+    r := bufio.NewReader(f)
+    var lines []string
+    var partial string
+    for {
+        line, err := r.ReadString('\n')
+        if err == io.EOF {
+            partial = line
+            break
+        }
+        if err != nil { return err }
+        lines = append(lines, line)
+        if n > 0 && len(lines) > n { lines = lines[1:] }
+    }
+    for _, line := range lines {
+        writeLine(w, line)
+    }
+    if !follow { return nil }
+
+    tick := time.NewTicker(500 * time.Millisecond)
+    defer tick.Stop()
+    for {
+        line, err := r.ReadString('\n')
+        if err == nil {
+            writeLine(w, partial+line)
+            partial = ""
+            continue
+        }
+        if err != io.EOF { return err }
+        partial += line
+        select {
+        case <-stop:
+            return nil
+        case <-tick.C:
+        }
+    }
+}
+
+func writeLine(w io.Writer, line string) {
+    line = strings.TrimSpace(line)
+    if line == "" { return }
+    var ev Event
+    if err := json.Unmarshal([]byte(line), &ev); err != nil {
+        // Keep malformed lines visible rather than dropping them.
+        fmt.Fprintln(w, line)
+        return
+    }
+    fmt.Fprintln(w, Format(ev))
+}
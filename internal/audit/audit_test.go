@@ -0,0 +1,46 @@
+package audit
+
+import (
+    "bufio"
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "heimdal/internal/redact"
+)
+
+// A secret that is also a common word must not break the log's structure.
+func TestLogRedactsValuesNotStructure(t *testing.T) {
+    dir := t.TempDir()
+    l, err := Open(dir, "0123456789abcdef")
+    if err != nil { t.Fatal(err) }
+    r, err := redact.New(nil)
+    if err != nil { t.Fatal(err) }
+    r.AddEnv([]string{"MY_API_KEY=session"}) // too plain to be guessed a secret
+    r.Add("session", "exec")                 // but declared secrets are masked
+    l.Redact(r)
+    if err := l.Log(Event{Kind: KindSessionStart, App: "app", Workdir: "/tmp/session/x"}); err != nil { t.Fatal(err) }
+    if err := l.Log(Event{Kind: KindExec, App: "app", Argv: []string{"login", "--token", "session"}, EnvKeys: []string{"SESSION_ID"}}); err != nil { t.Fatal(err) }
+    if err := l.Close(); err != nil { t.Fatal(err) }
+
+    f, err := os.Open(filepath.Join(dir, FileName))
+    if err != nil { t.Fatal(err) }
+    defer f.Close()
+    var events []Event
+    sc := bufio.NewScanner(f)
+    for sc.Scan() {
+        var ev Event
+        if err := json.Unmarshal(sc.Bytes(), &ev); err != nil { t.Fatalf("bad line %s: %v", sc.Text(), err) }
+        events = append(events, ev)
+    }
+    if len(events) != 2 { t.Fatalf("got %d events, want 2", len(events)) }
+    if events[0].Kind != KindSessionStart || events[1].Kind != KindExec { t.Errorf("kinds = %q, %q", events[0].Kind, events[1].Kind) }
+    for _, ev := range events {
+        if ev.Session != "0123456789abcdef" { t.Errorf("session = %q", ev.Session) }
+    }
+    if got := events[0].Workdir; got != "/tmp/[REDACTED]/x" { t.Errorf("workdir = %q", got) }
+    if got := strings.Join(events[1].Argv, " "); got != "login --token [REDACTED]" { t.Errorf("argv = %q", got) }
+    if got := events[1].EnvKeys; len(got) != 1 || got[0] != "SESSION_ID" { t.Errorf("env keys = %q", got) }
+}
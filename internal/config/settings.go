@@ -0,0 +1,284 @@
+package config
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "heimdal/internal/redact"
+
+    "gopkg.in/yaml.v3"
+)
+
+// Layer names, lowest precedence first.
+const (
+    LayerDefault = "default"
+    LayerUser    = "user"
+    LayerProject = "project"
+    LayerEnv     = "env"
+    LayerFlag    = "flag"
+)
+
+// ProjectFile is the per-project config, found by walking up from cwd.
+const ProjectFile = ".heimdall.yaml"
+
+// Key describes a setting and where it can be overridden.
+type Key struct {
+    Name    string
+    Default string
+    Env     string
+    Help    string
+    // Check validates a value before it is accepted from any layer.
+    Check func(string) error
+    // ProjectCheck additionally vets a value from a project .heimdall.yaml,
+    // which comes with the repository rather than from the user, against
+    // the value the user's own layers (defaults and user config) give. A
+    // value it rejects is ignored with a warning.
+    ProjectCheck func(v, user string) error
+}
+
+// Keys lists every known setting.
+var Keys = []Key{
+    {Name: "profile", Default: "permissive", Env: "HEIMDAL_PROFILE", Help: "default profile: permissive|restricted", Check: oneOf("permissive", "restricted"), ProjectCheck: tighten("permissive", "restricted")},
+    {Name: "prompt_prefix", Default: "[hd] ", Env: "HEIMDAL_PROMPT_PREFIX", Help: "prompt prefix shown by heimdal shell"},
+    {Name: "exec_trace", Default: "on", Env: "HEIMDAL_EXEC_TRACE", Help: "record execs by wrapped apps' child processes (Linux): on|off", Check: oneOf("on", "off"), ProjectCheck: tighten("off", "on")},
+    {Name: "git_checkpoint", Default: "on", Env: "HEIMDAL_GIT_CHECKPOINT", Help: "commit the working tree to refs/heimdal/<session> before run in a git repo: on|off", Check: oneOf("on", "off"), ProjectCheck: tighten("off", "on")},
+    {Name: "redact_patterns", Default: "", Env: "HEIMDAL_REDACT_PATTERNS", Help: "extra regexps (whitespace-separated) masked in audit logs, recordings and context files", Check: redact.CheckPatterns, ProjectCheck: keepPatterns},
+    {Name: "secret_store", Default: "file", Env: "HEIMDAL_SECRET_STORE", Help: "where secret:// values live: file (passphrase-encrypted) | keyring", Check: oneOf("file", "keyring")},
+    {Name: "shell_gate", Default: "allow", Env: "HEIMDAL_SHELL_GATE", Help: "heimdal shell command gate: off, or the default decision allow|ask|deny", Check: oneOf("off", "allow", "ask", "deny"), ProjectCheck: tighten("off", "allow", "ask", "deny")},
+    {Name: "wiki_embedder", Default: "hash", Env: "HEIMDAL_WIKI_EMBEDDER", Help: "wiki embedder: hash | cmd:<command> [args...] (cmd: not from .heimdall.yaml)", ProjectCheck: noCommand},
+}
+
+func oneOf(allowed ...string) func(string) error {
+    return func(v string) error {
+        for _, a := range allowed {
+            if v == a { return nil }
+        }
+        return fmt.Errorf("must be one of %s", strings.Join(allowed, "|"))
+    }
+}
+
+// tighten lets a project only move a setting towards the end of order,
+// loosest first: a cloned repository must not weaken the user's settings.
+func tighten(order ...string) func(v, user string) error {
+    rank := func(v string) int {
+        for i, o := range order {
+            if v == o { return i }
+        }
+        return -1
+    }
+    return func(v, user string) error {
+        // Unknown values are left for Check to reject.
+        if r := rank(v); r >= 0 && r < rank(user) { return fmt.Errorf("a project can only tighten it (yours is %s)", user) }
+        return nil
+    }
+}
+
+// keepPatterns lets a project add redact patterns but not drop the user's.
+func keepPatterns(v, user string) error {
+    have := strings.Fields(v)
+    for _, p := range strings.Fields(user) {
+        found := false
+        for _, h := range have {
+            if h == p { found = true }
+        }
+        if !found { return fmt.Errorf("a project can only add patterns (it drops %s)", p) }
+    }
+    return nil
+}
+
+// noCommand refuses "cmd:" values: a cloned repository must not be able to
+// make heimdal run a program of its choosing.
+func noCommand(v, user string) error {
+    if strings.HasPrefix(strings.TrimSpace(v), "cmd:") { return errors.New("commands can only be set in the user config, env or flags") }
+    return nil
+}
+
+// LookupKey returns the Key named name.
+func LookupKey(name string) (Key, bool) {
+    for _, k := range Keys {
+        if k.Name == name { return k, true }
+    }
+    return Key{}, false
+}
+
+// Value is a resolved setting together with the layer it came from.
+type Value struct {
+    Key    string
+    Value  string
+    Layer  string
+    Source string // file path, env var or flag name
+}
+
+// Config is the merged view of all layers.
+type Config struct {
+    values map[string]Value
+    user   map[string]string // values from defaults and the user config
+    // UserPath and ProjectPath are the files consulted; ProjectPath is empty
+    // when no .heimdall.yaml was found.
+    UserPath    string
+    ProjectPath string
+    // Warnings lists project values that were ignored.
+    Warnings []string
+}
+
+// Load merges defaults, ~/.heimdall/config.yaml, the nearest .heimdall.yaml,
+// HEIMDAL_* env vars and flags (key -> value), in that order.
+func Load(cwd string, flags map[string]string) (*Config, error) {
+    c := &Config{values: map[string]Value{}}
+    for _, k := range Keys {
+        c.values[k.Name] = Value{Key: k.Name, Value: k.Default, Layer: LayerDefault}
+    }
+
+    if p, err := UserConfigPath(); err == nil {
+        c.UserPath = p
+        if err := c.mergeFile(p, LayerUser); err != nil { return nil, err }
+    }
+    c.user = map[string]string{}
+    for name, v := range c.values { c.user[name] = v.Value }
+    if p := FindProjectConfig(cwd); p != "" {
+        c.ProjectPath = p
+        if err := c.mergeFile(p, LayerProject); err != nil { return nil, err }
+    }
+    for _, k := range Keys {
+        if v, ok := os.LookupEnv(k.Env); ok {
+            if err := c.set(k, v, LayerEnv, k.Env); err != nil { return nil, err }
+        }
+    }
+    names := make([]string, 0, len(flags))
+    for name := range flags { names = append(names, name) }
+    sort.Strings(names)
+    for _, name := range names {
+        k, ok := LookupKey(name)
+        if !ok { return nil, fmt.Errorf("unknown setting %q", name) }
+        if err := c.set(k, flags[name], LayerFlag, "--"+strings.ReplaceAll(name, "_", "-")); err != nil { return nil, err }
+    }
+    return c, nil
+}
+
+func (c *Config) set(k Key, v, layer, source string) error {
+    if k.Check != nil {
+        if err := k.Check(v); err != nil {
+            return fmt.Errorf("%s: %s: %w", source, k.Name, err)
+        }
+    }
+    c.values[k.Name] = Value{Key: k.Name, Value: v, Layer: layer, Source: source}
+    return nil
+}
+
+func (c *Config) mergeFile(path, layer string) error {
+    kv, err := readFile(path)
+    if err != nil { return err }
+    for name, v := range kv {
+        k, ok := LookupKey(name)
+        if !ok { return fmt.Errorf("%s: unknown setting %q", path, name) }
+        if layer == LayerProject {
+            if err := c.CheckProject(name, v); err != nil {
+                c.Warnings = append(c.Warnings, fmt.Sprintf("%s: ignoring %s: %s: %v", path, name, v, err))
+                continue
+            }
+        }
+        if err := c.set(k, v, layer, path); err != nil { return err }
+    }
+    return nil
+}
+
+// CheckProject reports whether a project .heimdall.yaml may set key to v,
+// given the user's own settings.
+func (c *Config) CheckProject(key, v string) error {
+    k, ok := LookupKey(key)
+    if !ok { return fmt.Errorf("unknown setting %q", key) }
+    if k.ProjectCheck == nil { return nil }
+    return k.ProjectCheck(v, c.user[key])
+}
+
+// Get returns the resolved value for key.
+func (c *Config) Get(key string) (Value, bool) {
+    v, ok := c.values[key]
+    return v, ok
+}
+
+// String returns the resolved value for key, or "" if unknown.
+func (c *Config) String(key string) string {
+    return c.values[key].Value
+}
+
+// All returns every resolved value, sorted by key.
+func (c *Config) All() []Value {
+    out := make([]Value, 0, len(c.values))
+    for _, v := range c.values { out = append(out, v) }
+    sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+    return out
+}
+
+// UserConfigPath returns ~/.heimdall/config.yaml.
+func UserConfigPath() (string, error) {
+    home, err := os.UserHomeDir()
+    if err != nil { return "", err }
+    return filepath.Join(home, ".heimdall", "config.yaml"), nil
+}
+
+// FindProjectConfig returns the nearest .heimdall.yaml at or above dir, or "".
+func FindProjectConfig(dir string) string {
+    for {
+        p := filepath.Join(dir, ProjectFile)
+        if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+            return p
+        }
+        parent := filepath.Dir(dir)
+        if parent == dir { return "" }
+        dir = parent
+    }
+}
+
+// readFile reads a flat key: value YAML file; a missing file is empty.
+func readFile(path string) (map[string]string, error) {
+    b, err := os.ReadFile(path)
+    if errors.Is(err, os.ErrNotExist) { return nil, nil }
+    if err != nil { return nil, err }
+    var kv map[string]string
+    if err := yaml.Unmarshal(b, &kv); err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    return kv, nil
+}
+
+// SetInFile writes key: value into the YAML file at path, keeping other
+// keys and comments intact.
+func SetInFile(path, key, value string) error {
+    k, ok := LookupKey(key)
+    if !ok { return fmt.Errorf("unknown setting %q", key) }
+    if k.Check != nil {
+        if err := k.Check(value); err != nil { return fmt.Errorf("%s: %w", key, err) }
+    }
+    var doc yaml.Node
+    b, err := os.ReadFile(path)
+    if err != nil && !errors.Is(err, os.ErrNotExist) { return err }
+    if len(b) > 0 {
+        if err := yaml.Unmarshal(b, &doc); err != nil { return fmt.Errorf("%s: %w", path, err) }
+    }
+    if len(doc.Content) == 0 {
+        doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+    }
+    root := doc.Content[0]
+    if root.Kind != yaml.MappingNode { return fmt.Errorf("%s: expected a mapping at top level", path) }
+    val := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+    found := false
+    for i := 0; i+1 < len(root.Content); i += 2 {
+        if root.Content[i].Value == key {
+            val.LineComment = root.Content[i+1].LineComment
+            root.Content[i+1] = val
+            found = true
+        }
+    }
+    if !found {
+        root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, val)
+    }
+    out, err := yaml.Marshal(&doc)
+    if err != nil { return err }
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { return err }
+    return os.WriteFile(path, out, 0o644)
+}
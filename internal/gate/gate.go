@@ -0,0 +1,186 @@
+package gate
+
+import (
+    "bufio"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net"
+    "os"
+    "sync"
+    "time"
+
+    "golang.org/x/term"
+)
+
+// CheckArg is the hidden first argument that makes the heimdal binary act as
+// the shell hook client: `heimdal __gate-check <command line>`.
+const CheckArg = "__gate-check"
+
+// SocketName is the control socket inside the session dir.
+const SocketName = "control.sock"
+
+// SocketEnv tells the hook where the control socket is.
+const SocketEnv = "HEIMDAL_CONTROL_SOCKET"
+
+// Decision is a policy verdict for a command.
+type Decision string
+
+const (
+    Allow Decision = "allow"
+    Deny  Decision = "deny"
+    Ask   Decision = "ask"
+)
+
+// Request is what the hook reports before a command runs.
+type Request struct {
+    Command string `json:"command"`
+    // Line is the whole command line when Command is only part of it, as
+    // with bash, which reports each command of a pipeline separately.
+    Line  string `json:"line,omitempty"`
+    Cwd   string `json:"cwd,omitempty"`
+    Shell string `json:"shell,omitempty"`
+}
+
+// Response is the server's verdict. For Ask the hook prompts the user and
+// sends back an Answer on the same connection.
+type Response struct {
+    Decision Decision `json:"decision"`
+    Reason   string   `json:"reason,omitempty"`
+}
+
+// Answer is the user's reply to an Ask.
+type Answer struct {
+    Allow bool `json:"allow"`
+}
+
+// DecideFunc is the policy consulted for every command.
+type DecideFunc func(Request) (Decision, string)
+
+// RecordFunc receives every command with its final decision; asked is true
+// when the user made the call.
+type RecordFunc func(req Request, d Decision, reason string, asked bool)
+
+// Server answers hook requests on a unix socket.
+type Server struct {
+    ln     net.Listener
+    path   string
+    decide DecideFunc
+    record RecordFunc
+    wg     sync.WaitGroup
+}
+
+// Listen creates the control socket at path and serves it in the background.
+func Listen(path string, decide DecideFunc, record RecordFunc) (*Server, error) {
+    _ = os.Remove(path) // stale socket from a crashed session
+    ln, err := net.Listen("unix", path)
+    if err != nil { return nil, fmt.Errorf("control socket: %w", err) }
+    if err := os.Chmod(path, 0o600); err != nil {
+        ln.Close()
+        return nil, err
+    }
+    s := &Server{ln: ln, path: path, decide: decide, record: record}
+    s.wg.Add(1)
+    go s.serve()
+    return s, nil
+}
+
+func (s *Server) serve() {
+    defer s.wg.Done()
+    for {
+        conn, err := s.ln.Accept()
+        if err != nil { return }
+        s.wg.Add(1)
+        go func() {
+            defer s.wg.Done()
+            s.handle(conn)
+        }()
+    }
+}
+
+func (s *Server) handle(conn net.Conn) {
+    defer conn.Close()
+    r := bufio.NewReader(conn)
+    var req Request
+    line, err := r.ReadBytes('\n')
+    if err != nil || json.Unmarshal(line, &req) != nil { return }
+    d, reason := s.decide(req)
+    b, _ := json.Marshal(Response{Decision: d, Reason: reason})
+    if _, err := conn.Write(append(b, '\n')); err != nil { return }
+    if d != Ask {
+        s.record(req, d, reason, false)
+        return
+    }
+    // The user may take a while; a closed connection counts as a denial.
+    var ans Answer
+    line, err = r.ReadBytes('\n')
+    if err != nil || json.Unmarshal(line, &ans) != nil {
+        s.record(req, Deny, "no answer", true)
+        return
+    }
+    if ans.Allow {
+        s.record(req, Allow, "approved by user", true)
+    } else {
+        s.record(req, Deny, "denied by user", true)
+    }
+}
+
+// Close stops accepting requests, waits for open ones and removes the socket.
+func (s *Server) Close() error {
+    err := s.ln.Close()
+    s.wg.Wait()
+    _ = os.Remove(s.path)
+    return err
+}
+
+// Check is the hook side: it asks the server at socket about req and, for
+// Ask, prompts on the terminal. It reports whether the command may run.
+func Check(socket string, req Request) (bool, error) {
+    if socket == "" { return false, errors.New("control socket not set") }
+    conn, err := net.DialTimeout("unix", socket, 2*time.Second)
+    if err != nil { return false, fmt.Errorf("control socket: %w", err) }
+    defer conn.Close()
+    b, _ := json.Marshal(req)
+    if _, err := conn.Write(append(b, '\n')); err != nil { return false, err }
+    r := bufio.NewReader(conn)
+    line, err := r.ReadBytes('\n')
+    if err != nil { return false, fmt.Errorf("control socket: %w", err) }
+    var resp Response
+    if err := json.Unmarshal(line, &resp); err != nil { return false, err }
+    switch resp.Decision {
+    case Allow:
+        return true, nil
+    case Deny:
+        msg := "heimdal: denied: " + req.Command
+        if resp.Reason != "" { msg += " (" + resp.Reason + ")" }
+        fmt.Fprintln(os.Stderr, msg)
+        return false, nil
+    }
+    ok := prompt(req.Command, resp.Reason)
+    b, _ = json.Marshal(Answer{Allow: ok})
+    _, err = conn.Write(append(b, '\n'))
+    return ok, err
+}
+
+// prompt asks for a single keystroke on the controlling terminal, in raw
+// mode so it also works from inside a zsh line-editor widget. No terminal
+// means no.
+func prompt(command, reason string) bool {
+    tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+    if err != nil { return false }
+    defer tty.Close()
+    fmt.Fprintf(tty, "heimdal: run %q?", command)
+    if reason != "" { fmt.Fprintf(tty, " (%s)", reason) }
+    fmt.Fprint(tty, " [y/N] ")
+    fd := int(tty.Fd())
+    if st, err := term.MakeRaw(fd); err == nil {
+        defer term.Restore(fd, st)
+    }
+    var key [1]byte
+    _, _ = tty.Read(key[:])
+    ok := key[0] == 'y' || key[0] == 'Y'
+    answer := "no"
+    if ok { answer = "yes" }
+    fmt.Fprint(tty, answer+"\r\n")
+    return ok
+}
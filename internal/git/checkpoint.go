@@ -0,0 +1,115 @@
+package git
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// Checkpoints are commits of the whole working tree, untracked files
+// included (ignored ones are not), kept under a ref outside refs/heads so
+// they stay out of branches, logs and the stash. Taking and restoring them
+// goes through a throwaway index; the user's index is never touched.
+
+// CheckpointRef is where the checkpoint for a session is kept.
+func CheckpointRef(session string) string { return "refs/heimdal/" + session }
+
+// UndoRef is where a rollback saves the tree it replaced.
+func UndoRef(session string) string { return "refs/heimdal/undo/" + session }
+
+// WriteTree records the working tree as a tree object and returns its hash.
+func (r *Repo) WriteTree() (string, error) {
+    var tree string
+    err := r.withIndex(true, func(env []string) error {
+        if _, err := r.git(env, "", "add", "--all", "--", "."); err != nil { return err }
+        var err error
+        tree, err = r.git(env, "", "write-tree")
+        return err
+    })
+    return tree, err
+}
+
+// Commit wraps tree in a commit on top of HEAD and points ref at it.
+func (r *Repo) Commit(ref, tree, message string) (string, error) {
+    args := []string{"commit-tree", "--no-gpg-sign", tree, "-m", message}
+    if head := r.Head(); head != "" { args = append(args, "-p", head) }
+    commit, err := r.git(identity, "", args...)
+    if err != nil { return "", err }
+    if _, err := r.git(nil, "", "update-ref", "-m", message, ref, commit); err != nil { return "", err }
+    return commit, nil
+}
+
+// Checkpoint commits the working tree to ref and returns the commit.
+func (r *Repo) Checkpoint(ref, message string) (string, error) {
+    tree, err := r.WriteTree()
+    if err != nil { return "", err }
+    return r.Commit(ref, tree, message)
+}
+
+// Change is a file that differs between two trees: 'A' only in the second,
+// 'D' only in the first, 'M' (content or mode) or 'T' (type) in both.
+type Change struct {
+    Status byte
+    Path   string // slash-separated, relative to Root
+}
+
+// Diff lists the files that differ from tree-ish a to b.
+func (r *Repo) Diff(a, b string) ([]Change, error) {
+    out, err := r.git(nil, "", "diff-tree", "-r", "-z", "--no-renames", "--name-status", a, b)
+    if err != nil { return nil, err }
+    fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+    var changes []Change
+    for i := 0; i+1 < len(fields); i += 2 {
+        changes = append(changes, Change{Status: fields[i][0], Path: fields[i+1]})
+    }
+    return changes, nil
+}
+
+// Restore makes the working tree match commit for changes, as listed by
+// Diff(commit, <current tree>): files added since are deleted and the rest
+// are written back from commit.
+func (r *Repo) Restore(commit string, changes []Change) error {
+    var paths []string
+    for _, c := range changes {
+        if c.Status != 'A' {
+            paths = append(paths, c.Path)
+            continue
+        }
+        path := filepath.Join(r.Root, filepath.FromSlash(c.Path))
+        if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) { return err }
+        r.removeEmptyParents(path)
+    }
+    if len(paths) == 0 { return nil }
+    return r.withIndex(false, func(env []string) error {
+        if _, err := r.git(env, "", "read-tree", commit); err != nil { return err }
+        _, err := r.git(env, strings.Join(paths, "\x00")+"\x00", "checkout-index", "--force", "-z", "--stdin")
+        return err
+    })
+}
+
+// removeEmptyParents deletes the directories above path that are left
+// empty, up to Root.
+func (r *Repo) removeEmptyParents(path string) {
+    for dir := filepath.Dir(path); dir != r.Root && strings.HasPrefix(dir, r.Root); dir = filepath.Dir(dir) {
+        if os.Remove(dir) != nil { return } // not empty
+    }
+}
+
+// withIndex runs fn with GIT_INDEX_FILE set to a temporary index, seeded
+// from the real one when seed is set so unchanged files are not rehashed.
+func (r *Repo) withIndex(seed bool, fn func(env []string) error) error {
+    f, err := os.CreateTemp(r.GitDir, "heimdal-index-*")
+    if err != nil { return err }
+    tmp := f.Name()
+    f.Close()
+    defer os.Remove(tmp)
+    // git wants a valid index or none at all.
+    b, err := os.ReadFile(filepath.Join(r.GitDir, "index"))
+    if seed && err == nil {
+        if err := os.WriteFile(tmp, b, 0o600); err != nil { return err }
+    } else {
+        os.Remove(tmp)
+    }
+    return fn([]string{"GIT_INDEX_FILE=" + tmp})
+}
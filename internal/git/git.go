@@ -0,0 +1,73 @@
+package git
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+)
+
+// ErrNotRepo is returned by Open outside a git working tree, or when git is
+// not installed.
+var ErrNotRepo = errors.New("not a git repository")
+
+// identity signs heimdal's own commits, so they work without user.name set.
+var identity = []string{
+    "GIT_AUTHOR_NAME=heimdal", "GIT_AUTHOR_EMAIL=heimdal@localhost",
+    "GIT_COMMITTER_NAME=heimdal", "GIT_COMMITTER_EMAIL=heimdal@localhost",
+}
+
+// Repo is the working tree of a git repository, driven through the git CLI.
+type Repo struct {
+    Root   string // top of the working tree
+    GitDir string // its git dir (.git, or .git/worktrees/<name>)
+}
+
+// Open finds the repository containing dir.
+func Open(dir string) (*Repo, error) {
+    if _, err := exec.LookPath("git"); err != nil { return nil, ErrNotRepo }
+    r := &Repo{Root: dir}
+    out, err := r.git(nil, "", "rev-parse", "--show-toplevel", "--absolute-git-dir")
+    if err != nil { return nil, ErrNotRepo }
+    lines := strings.Split(out, "\n")
+    if len(lines) != 2 { return nil, ErrNotRepo }
+    r.Root, r.GitDir = filepath.FromSlash(lines[0]), filepath.FromSlash(lines[1])
+    return r, nil
+}
+
+// git runs git in the working tree with extra env and stdin, returning its
+// output without the trailing newline.
+func (r *Repo) git(env []string, stdin string, args ...string) (string, error) {
+    cmd := exec.Command("git", append([]string{"-C", r.Root}, args...)...)
+    cmd.Env = append(os.Environ(), env...)
+    cmd.Stdin = strings.NewReader(stdin)
+    var stderr bytes.Buffer
+    cmd.Stderr = &stderr
+    out, err := cmd.Output()
+    if err != nil {
+        return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+    }
+    return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// Resolve returns the commit a ref points at.
+func (r *Repo) Resolve(ref string) (string, error) {
+    out, err := r.git(nil, "", "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+    if err != nil { return "", fmt.Errorf("no such ref: %s", ref) }
+    return out, nil
+}
+
+// Head returns the commit HEAD points at, or "" on an unborn branch.
+func (r *Repo) Head() string {
+    out, _ := r.Resolve("HEAD")
+    return out
+}
+
+// Short abbreviates a commit hash for display.
+func Short(commit string) string {
+    if len(commit) > 7 { return commit[:7] }
+    return commit
+}
@@ -0,0 +1,92 @@
+package git
+
+import (
+    "errors"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// WorktreeBranch is the branch a session's worktree is checked out on.
+func WorktreeBranch(session string) string { return "heimdal/" + session }
+
+// AddWorktree checks branch out into a new linked worktree at path,
+// creating the branch from HEAD if it does not exist yet.
+func (r *Repo) AddWorktree(path, branch string) (*Repo, error) {
+    args := []string{"worktree", "add", "--quiet", path, branch}
+    if _, err := r.Resolve("refs/heads/" + branch); err != nil {
+        if r.Head() == "" { return nil, errors.New("the repository has no commits yet") }
+        args = []string{"worktree", "add", "--quiet", "-b", branch, path, "HEAD"}
+    }
+    if _, err := r.git(nil, "", args...); err != nil { return nil, err }
+    return Open(path)
+}
+
+// RemoveWorktree deletes the linked worktree at path, changes and all.
+func (r *Repo) RemoveWorktree(path string) error {
+    _, err := r.git(nil, "", "worktree", "remove", "--force", path)
+    return err
+}
+
+// PruneWorktrees drops git's records of worktrees whose directories are gone.
+func (r *Repo) PruneWorktrees() error {
+    _, err := r.git(nil, "", "worktree", "prune")
+    return err
+}
+
+// DeleteBranch deletes branch, merged or not.
+func (r *Repo) DeleteBranch(branch string) error {
+    _, err := r.git(nil, "", "branch", "--quiet", "-D", branch)
+    return err
+}
+
+// Dirty reports whether the working tree has changes, untracked files
+// included.
+func (r *Repo) Dirty() (bool, error) {
+    out, err := r.git(nil, "", "status", "--porcelain")
+    return out != "", err
+}
+
+// Ahead counts the commits on branch that HEAD does not have.
+func (r *Repo) Ahead(branch string) (int, error) {
+    out, err := r.git(nil, "", "rev-list", "--count", "HEAD..refs/heads/"+branch)
+    if err != nil { return 0, err }
+    return strconv.Atoi(out)
+}
+
+// CommitAll commits every change in the working tree, untracked files
+// included, as the user or else as heimdal. Hooks are skipped: the commit
+// only carries an app's uncommitted work over to a merge.
+func (r *Repo) CommitAll(message string) error {
+    if _, err := r.git(nil, "", "add", "--all"); err != nil { return err }
+    _, err := r.git(r.identity(), "", "commit", "--quiet", "--no-verify", "-m", message)
+    return err
+}
+
+// Merge merges branch into HEAD. On conflicts the merge is left in
+// progress for the user to resolve or abort.
+func (r *Repo) Merge(branch, message string) error {
+    _, err := r.git(r.identity(), "", "merge", "--no-edit", "-m", message, "refs/heads/"+branch)
+    return err
+}
+
+// identity returns heimdal's identity unless the user has one configured.
+func (r *Repo) identity() []string {
+    if _, err := r.git(nil, "", "config", "user.email"); err != nil { return identity }
+    return nil
+}
+
+// Rel returns dir relative to Root, or "." if it is outside.
+func (r *Repo) Rel(dir string) string {
+    if d, err := filepath.EvalSymlinks(dir); err == nil { dir = d }
+    rel, err := filepath.Rel(r.Root, dir)
+    if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) { return "." }
+    return rel
+}
+
+// CurrentBranch returns the short name of the checked-out branch, or "" when
+// HEAD is detached.
+func (r *Repo) CurrentBranch() string {
+    out, _ := r.git(nil, "", "symbolic-ref", "--quiet", "--short", "HEAD")
+    return out
+}
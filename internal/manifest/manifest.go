@@ -1,76 +1,150 @@
 package manifest
 
 import (
-    "bufio"
+    "bytes"
     "errors"
     "fmt"
     "os"
+    "path"
     "path/filepath"
+    "reflect"
     "regexp"
+    "strconv"
     "strings"
+    "time"
+
+    "gopkg.in/yaml.v3"
 )
 
-// Manifest is a minimal subset parsed from YAML.
-// Only top-level fields name, cmd, args, env are supported in MVP.
+// Manifest describes a wrapped app, decoded from apps/<name>.yaml.
+// Unknown keys are rejected so typos fail loudly.
 type Manifest struct {
-    Name string
-    Cmd  string
-    Args []string
-    Env  map[string]string
+    Name     string            `yaml:"name"`
+    Cmd      string            `yaml:"cmd"`
+    Args     []string          `yaml:"args"`
+    Env      map[string]string `yaml:"env"`
+    // EnvPassthrough lists host env vars (names or globs such as LC_*) the
+    // app gets under the restricted profile, besides PATH, HOME and TERM.
+    EnvPassthrough []string `yaml:"env_passthrough,omitempty"`
+    // EnvDeny lists host env vars (names or globs) the app never gets.
+    EnvDeny  []string          `yaml:"env_deny,omitempty"`
+    Tags     []string          `yaml:"tags,omitempty"`
+    // Adapter names the AI CLI adapter (claude, gemini) or "none"; by default
+    // it is picked from the base name of Cmd.
+    Adapter  string            `yaml:"adapter,omitempty"`
+    Policies Policies          `yaml:"policies,omitempty"`
+    Resources Resources        `yaml:"resources,omitempty"`
+    // Timeout and IdleTimeout stop the app (SIGTERM, then SIGKILL) once it
+    // has run this long, or gone this long without output, e.g. 30m.
+    Timeout     string `yaml:"timeout,omitempty"`
+    IdleTimeout string `yaml:"idle_timeout,omitempty"`
+    // Isolation "worktree" runs the app in a git worktree of its own, as
+    // with `heimdal run --worktree`; "none" is the default.
+    Isolation string `yaml:"isolation,omitempty"`
+    Context  Context           `yaml:"context,omitempty"`
+}
+
+// Timeouts parses Timeout and IdleTimeout; empty values are 0.
+func (m Manifest) Timeouts() (total, idle time.Duration) {
+    total, _ = time.ParseDuration(m.Timeout) // validated by Parse
+    idle, _ = time.ParseDuration(m.IdleTimeout)
+    return total, idle
+}
+
+// Resources limit the app and all its descendants (Linux). Zero values
+// are unlimited.
+type Resources struct {
+    Memory   string  `yaml:"memory,omitempty"`    // e.g. 512M or 2G (binary units)
+    CPU      float64 `yaml:"cpu,omitempty"`       // CPUs, e.g. 1.5
+    Pids     int     `yaml:"pids,omitempty"`      // max processes and threads
+    IOWeight int     `yaml:"io_weight,omitempty"` // 1-10000, default 100
+}
+
+// MemoryBytes parses Memory; 0 means unlimited.
+func (r Resources) MemoryBytes() (int64, error) {
+    if r.Memory == "" { return 0, nil }
+    return ParseSize(r.Memory)
+}
+
+// ParseSize parses a byte count with an optional binary unit suffix:
+// K, M, G or T, optionally followed by "i", "B" or "iB" (e.g. 512M, 1.5GiB).
+func ParseSize(s string) (int64, error) {
+    num := strings.TrimSpace(s)
+    num = strings.TrimSuffix(strings.TrimSuffix(num, "B"), "i")
+    mult := int64(1)
+    if n := len(num); n > 0 {
+        if i := strings.IndexByte("KMGT", num[n-1]&^0x20); i >= 0 {
+            mult = int64(1) << (10 * (i + 1))
+            num = num[:n-1]
+        }
+    }
+    v, err := strconv.ParseFloat(num, 64)
+    if err != nil || v < 0 { return 0, fmt.Errorf("invalid size %q (want e.g. 512M or 2G)", s) }
+    return int64(v * float64(mult)), nil
+}
+
+// Context controls which context files heimdal prepares for the app.
+type Context struct {
+    Wiki WikiContext `yaml:"wiki,omitempty"`
+    // MCP registers `heimdal mcp serve` for the app: context/mcp.json is
+    // written and its path exported as HEIMDAL_MCP_CONFIG.
+    MCP bool `yaml:"mcp,omitempty"`
+}
+
+// WikiContext controls context/wiki.md: the wiki pages most relevant to the
+// app name, manifest tags and repo, packed under a token budget.
+type WikiContext struct {
+    Enabled   *bool    `yaml:"enabled,omitempty"`    // default true
+    MaxPages  int      `yaml:"max_pages,omitempty"`  // default 5
+    MaxTokens int      `yaml:"max_tokens,omitempty"` // default 4000
+    Queries   []string `yaml:"queries,omitempty"`    // extra search terms
+}
+
+// On reports whether wiki context is enabled (the default).
+func (w WikiContext) On() bool {
+    return w.Enabled == nil || *w.Enabled
+}
+
+// Policies restrict a wrapped app. Network and Filesystem are enforced under
+// the restricted profile; Commands apply to every command heimdal observes.
+type Policies struct {
+    // Network is "allow" or "deny". Empty means deny under restricted.
+    Network    string           `yaml:"network,omitempty"`
+    Filesystem FilesystemPolicy `yaml:"filesystem,omitempty"`
+    // Commands are checked in order; the first matching rule decides.
+    Commands []CommandRule `yaml:"commands,omitempty"`
+}
+
+// CommandRule decides commands matching a glob or a regexp. Match is a glob
+// over the whole command line (words separated by single spaces) where * and ?
+// also match spaces and slashes; Regex is an unanchored RE2 expression.
+type CommandRule struct {
+    Action string `yaml:"action"` // allow | deny | ask
+    Match  string `yaml:"match,omitempty"`
+    Regex  string `yaml:"regex,omitempty"`
+    Reason string `yaml:"reason,omitempty"`
 }
 
-// Load reads a minimal YAML manifest.
+// FilesystemPolicy lists paths a wrapped app may read from and write to.
+// An empty list leaves that kind of access unrestricted.
+type FilesystemPolicy struct {
+    Read  []string `yaml:"read,omitempty"`
+    Write []string `yaml:"write,omitempty"`
+}
+
+// NetworkAllowed reports whether the manifest explicitly allows network access.
+func (p Policies) NetworkAllowed() bool {
+    return strings.EqualFold(p.Network, "allow")
+}
+
+// Load reads and validates a YAML manifest.
 func Load(path string) (Manifest, error) {
-    f, err := os.Open(path)
+    b, err := os.ReadFile(path)
     if err != nil {
         return Manifest{}, err
     }
-    defer f.Close()
-
-    m := Manifest{Env: map[string]string{}}
-    s := bufio.NewScanner(f)
-    inEnv := false
-    for s.Scan() {
-        line := strings.TrimRight(s.Text(), "\r\n")
-        if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
-            continue
-        }
-        if !strings.HasPrefix(line, " ") && strings.HasSuffix(line, ":") {
-            // entering a section
-            key := strings.TrimSuffix(strings.TrimSpace(line), ":")
-            if key == "env" {
-                inEnv = true
-            } else {
-                inEnv = false
-            }
-            continue
-        }
-        if !strings.HasPrefix(line, " ") {
-            inEnv = false
-            // root-level key: value
-            k, v, ok := splitKV(line)
-            if !ok { continue }
-            switch k {
-            case "name":
-                m.Name = v
-            case "cmd":
-                m.Cmd = v
-            case "args":
-                // attempt to parse YAML-ish list: ["a", "b"] or [a, b]
-                m.Args = parseList(v)
-            }
-            continue
-        }
-        if inEnv {
-            // env entries are indented
-            k, v, ok := splitKV(strings.TrimSpace(line))
-            if ok {
-                if m.Env == nil { m.Env = map[string]string{} }
-                m.Env[k] = v
-            }
-        }
-    }
-    if err := s.Err(); err != nil {
+    m, err := Parse(b, path)
+    if err != nil {
         return Manifest{}, err
     }
     if m.Name == "" {
@@ -84,81 +158,188 @@ func Load(path string) (Manifest, error) {
     return m, nil
 }
 
-func splitKV(line string) (string, string, bool) {
-    i := strings.Index(line, ":")
-    if i < 0 { return "", "", false }
-    k := strings.TrimSpace(line[:i])
-    v := strings.TrimSpace(line[i+1:])
-    v = trimQuotes(v)
-    return k, v, true
+// Parse decodes manifest YAML. Schema errors are reported as
+// "<source>:<line>:<col>: ..." and joined when there are several.
+func Parse(data []byte, source string) (Manifest, error) {
+    var root yaml.Node
+    if err := yaml.Unmarshal(data, &root); err != nil {
+        return Manifest{}, fmt.Errorf("%s: %w", source, err)
+    }
+    m := Manifest{Env: map[string]string{}}
+    if len(root.Content) == 0 {
+        return m, nil // empty document
+    }
+    doc := root.Content[0]
+    normalizeArgs(doc)
+    if errs := validate(doc, reflect.TypeOf(m), source, ""); len(errs) > 0 {
+        return Manifest{}, errors.Join(errs...)
+    }
+    if err := doc.Decode(&m); err != nil {
+        return Manifest{}, fmt.Errorf("%s: %w", source, err)
+    }
+    errs := checkCommandRules(doc, m.Policies.Commands, source)
+    errs = append(errs, checkEnvPatterns(doc, "env_passthrough", m.EnvPassthrough, source)...)
+    errs = append(errs, checkEnvPatterns(doc, "env_deny", m.EnvDeny, source)...)
+    errs = append(errs, checkResources(doc, m.Resources, source)...)
+    for _, key := range []string{"timeout", "idle_timeout"} {
+        if n := lookup(doc, key); n != nil && n.Value != "" {
+            if d, err := time.ParseDuration(n.Value); err != nil || d < 0 {
+                errs = append(errs, fmt.Errorf("%s:%d:%d: %s: invalid duration %q (want e.g. 90s, 30m or 2h)", source, n.Line, n.Column, key, n.Value))
+            }
+        }
+    }
+    if n := lookup(doc, "isolation"); n != nil && m.Isolation != "" && m.Isolation != "none" && m.Isolation != "worktree" {
+        errs = append(errs, fmt.Errorf("%s:%d:%d: isolation must be none or worktree, got %q", source, n.Line, n.Column, m.Isolation))
+    }
+    if n := lookup(doc, "policies", "network"); n != nil && m.Policies.Network != "" && m.Policies.Network != "allow" && m.Policies.Network != "deny" {
+        errs = append(errs, fmt.Errorf("%s:%d:%d: policies.network must be allow or deny, got %q", source, n.Line, n.Column, m.Policies.Network))
+    }
+    if len(errs) > 0 {
+        return Manifest{}, errors.Join(errs...)
+    }
+    if m.Env == nil { m.Env = map[string]string{} }
+    return m, nil
+}
+
+// checkCommandRules validates policies.commands beyond its shape, pointing
+// at the offending rule's position.
+func checkCommandRules(doc *yaml.Node, rules []CommandRule, source string) []error {
+    items := lookup(doc, "policies", "commands")
+    var errs []error
+    for i, r := range rules {
+        line, col := 0, 0
+        if items != nil && i < len(items.Content) {
+            line, col = items.Content[i].Line, items.Content[i].Column
+        }
+        errorf := func(format string, args ...interface{}) {
+            errs = append(errs, fmt.Errorf("%s:%d:%d: policies.commands[%d]: %s", source, line, col, i, fmt.Sprintf(format, args...)))
+        }
+        switch r.Action {
+        case "allow", "deny", "ask":
+        default:
+            errorf("action must be allow, deny or ask, got %q", r.Action)
+        }
+        if (r.Match == "") == (r.Regex == "") {
+            errorf("set exactly one of match or regex")
+        }
+        if r.Regex != "" {
+            if _, err := regexp.Compile(r.Regex); err != nil { errorf("%v", err) }
+        }
+    }
+    return errs
+}
+
+// checkEnvPatterns validates the env var globs listed under key.
+func checkEnvPatterns(doc *yaml.Node, key string, patterns []string, source string) []error {
+    items := lookup(doc, key)
+    var errs []error
+    for i, p := range patterns {
+        line, col := 0, 0
+        if items != nil && i < len(items.Content) {
+            line, col = items.Content[i].Line, items.Content[i].Column
+        }
+        if _, err := path.Match(p, ""); err != nil || p == "" {
+            errs = append(errs, fmt.Errorf("%s:%d:%d: %s[%d]: invalid env var pattern %q", source, line, col, key, i, p))
+        }
+    }
+    return errs
+}
+
+// checkResources validates resources values against their ranges.
+func checkResources(doc *yaml.Node, r Resources, source string) []error {
+    var errs []error
+    check := func(key string, bad bool, format string, args ...interface{}) {
+        if !bad { return }
+        line, col := 0, 0
+        if n := lookup(doc, "resources", key); n != nil { line, col = n.Line, n.Column }
+        errs = append(errs, fmt.Errorf("%s:%d:%d: resources.%s: %s", source, line, col, key, fmt.Sprintf(format, args...)))
+    }
+    _, err := r.MemoryBytes()
+    check("memory", err != nil, "%v", err)
+    check("cpu", r.CPU < 0, "must not be negative")
+    check("pids", r.Pids < 0, "must not be negative")
+    check("io_weight", r.IOWeight < 0 || r.IOWeight > 10000, "must be between 1 and 10000")
+    return errs
+}
+
+// MatchEnv reports whether the env var name matches one of patterns.
+func MatchEnv(patterns []string, name string) bool {
+    for _, p := range patterns {
+        if ok, _ := path.Match(p, name); ok { return true }
+    }
+    return false
 }
 
-var listRe = regexp.MustCompile(`^\[(.*)\]$`)
+// lookup follows mapping keys from n, resolving aliases and << merges;
+// nil if absent.
+func lookup(n *yaml.Node, keys ...string) *yaml.Node {
+    for _, key := range keys {
+        n = lookupKey(n, key)
+    }
+    for n != nil && n.Kind == yaml.AliasNode { n = n.Alias }
+    return n
+}
 
-func parseList(s string) []string {
-    s = strings.TrimSpace(s)
-    m := listRe.FindStringSubmatch(s)
-    if len(m) != 2 {
-        s = trimQuotes(s)
-        if s == "" { return nil }
-        return strings.Fields(s)
+// lookupKey returns the value of key in mapping n. A key set in n itself
+// wins over merged ones, and earlier merged mappings over later ones.
+func lookupKey(n *yaml.Node, key string) *yaml.Node {
+    for n != nil && n.Kind == yaml.AliasNode { n = n.Alias }
+    if n == nil || n.Kind != yaml.MappingNode { return nil }
+    var next *yaml.Node
+    var merges []*yaml.Node
+    for i := 0; i+1 < len(n.Content); i += 2 {
+        switch n.Content[i].Value {
+        case key:
+            next = n.Content[i+1]
+        case "<<":
+            if v := n.Content[i+1]; v.Kind == yaml.SequenceNode {
+                merges = append(merges, v.Content...)
+            } else {
+                merges = append(merges, v)
+            }
+        }
     }
-    inner := m[1]
-    if strings.TrimSpace(inner) == "" { return nil }
-    // split on commas not considering nested quotes heavily; then trim
-    parts := strings.Split(inner, ",")
-    out := make([]string, 0, len(parts))
-    for _, p := range parts {
-        q := strings.TrimSpace(trimQuotes(p))
-        if q != "" { out = append(out, q) }
+    for _, m := range merges {
+        if next != nil { break }
+        next = lookupKey(m, key)
     }
-    return out
+    return next
 }
 
-func trimQuotes(s string) string {
-    s = strings.TrimSpace(s)
-    if len(s) >= 2 {
-        if (s[0] == '"' && s[len(s)-1] == '"') || (s[0] == '\'' && s[len(s)-1] == '\'') {
-            return s[1:len(s)-1]
+// normalizeArgs keeps the old shorthand `args: --foo --bar` working by
+// turning a scalar args value into a list split on whitespace.
+func normalizeArgs(doc *yaml.Node) {
+    if doc.Kind != yaml.MappingNode { return }
+    for i := 0; i+1 < len(doc.Content); i += 2 {
+        k, v := doc.Content[i], doc.Content[i+1]
+        if k.Value != "args" || v.Kind != yaml.ScalarNode || v.Tag == "!!null" { continue }
+        fields := strings.Fields(v.Value)
+        seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: v.Line, Column: v.Column}
+        for _, f := range fields {
+            seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: f, Line: v.Line, Column: v.Column})
         }
+        doc.Content[i+1] = seq
     }
-    return s
 }
 
-// Save writes a minimal YAML manifest with fields we support.
+// Save writes a manifest as YAML.
 func Save(path string, m Manifest) error {
     if m.Name == "" || m.Cmd == "" {
         return errors.New("manifest requires name and cmd")
     }
-    var b strings.Builder
-    fmt.Fprintf(&b, "name: %s\n", m.Name)
-    fmt.Fprintf(&b, "cmd: %s\n", m.Cmd)
-    if len(m.Args) > 0 {
-        // write as YAML list on one line
-        fmt.Fprintf(&b, "args: [")
-        for i, a := range m.Args {
-            if i > 0 { b.WriteString(", ") }
-            fmt.Fprintf(&b, "\"%s\"", escapeQuote(a))
-        }
-        b.WriteString("]\n")
-    } else {
-        fmt.Fprintf(&b, "args: []\n")
-    }
-    if len(m.Env) > 0 {
-        b.WriteString("env:\n")
-        for k, v := range m.Env {
-            fmt.Fprintf(&b, "  %s: %s\n", k, v)
-        }
-    } else {
-        b.WriteString("env: {}\n")
+    if m.Args == nil { m.Args = []string{} }
+    if m.Env == nil { m.Env = map[string]string{} }
+    var buf bytes.Buffer
+    enc := yaml.NewEncoder(&buf)
+    enc.SetIndent(2)
+    if err := enc.Encode(m); err != nil {
+        return err
+    }
+    if err := enc.Close(); err != nil {
+        return err
     }
     if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
         return err
     }
-    return os.WriteFile(path, []byte(b.String()), 0o644)
+    return os.WriteFile(path, buf.Bytes(), 0o644)
 }
-
-func escapeQuote(s string) string {
-    return strings.ReplaceAll(s, "\"", "\\\"")
-}
-
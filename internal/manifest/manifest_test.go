@@ -0,0 +1,60 @@
+package manifest
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestParseSchema(t *testing.T) {
+    tests := []struct {
+        name, yaml string
+        errs       []string // substrings, one per expected error; none means valid
+    }{
+        {"minimal", "name: a\ncmd: a\n", nil},
+        {"empty", "", nil},
+        {"unknown key", "name: a\npolices: {}\n", []string{`m.yaml:2:1: manifest: unknown key "polices" (did you mean "policies"?)`}},
+        {"nested unknown key", "policies:\n  filesystem:\n    reed: [./]\n", []string{`m.yaml:3:5: policies.filesystem: unknown key "reed" (did you mean "read"?)`}},
+        {"unknown key far from any", "zzzzzzzz: 1\n", []string{`m.yaml:1:1: manifest: unknown key "zzzzzzzz"`}},
+        {"several errors", "foo: 1\nbar: 2\n", []string{`m.yaml:1:1: manifest: unknown key "foo"`, `m.yaml:2:1: manifest: unknown key "bar"`}},
+        {"top-level x- key", "x-common: &c\n  anything: [1, 2]\nname: a\n", nil},
+        {"nested x- key", "policies:\n  x-note: hi\n", []string{`m.yaml:2:3: policies: unknown key "x-note"`}},
+        {"merge key", "x-base: &base\n  network: allow\npolicies:\n  <<: *base\n  filesystem:\n    read: [./]\n", nil},
+        {"merge key with unknown key", "x-base: &base\n  netwrok: allow\npolicies:\n  <<: *base\n", []string{`m.yaml:2:3: policies: unknown key "netwrok" (did you mean "network"?)`}},
+        {"merge list", "x-a: &a\n  network: deny\nx-b: &b\n  bogus: 1\npolicies:\n  <<: [*a, *b]\n", []string{`m.yaml:4:3: policies: unknown key "bogus"`}},
+        {"mapping for list", "args:\n  a: b\n", []string{`m.yaml:2:3: args: expected a list, got a mapping`}},
+        {"list for scalar", "cmd: [a, b]\n", []string{`m.yaml:1:6: cmd: expected a scalar value, got a list`}},
+        {"scalar for mapping", "policies: deny\n", []string{`m.yaml:1:11: policies: expected a mapping, got scalar "deny"`}},
+        {"scalar args shorthand", "args: --foo --bar\n", nil},
+        {"network allow", "policies:\n  network: allow\n", nil},
+        {"network deny", "policies:\n  network: deny\n", nil},
+        {"network typo", "policies:\n  network: alow\n", []string{`m.yaml:2:12: policies.network must be allow or deny, got "alow"`}},
+        {"network bool", "policies:\n  network: true\n", []string{`m.yaml:2:12: policies.network must be allow or deny, got "true"`}},
+        {"network via merge", "x-n: &n\n  network: open\npolicies:\n  <<: *n\n", []string{`m.yaml:2:12: policies.network must be allow or deny, got "open"`}},
+        {"command action", "policies:\n  commands:\n    - {action: maybe, match: \"rm *\"}\n", []string{`m.yaml:3:7: policies.commands[0]: action must be allow, deny or ask`}},
+        {"isolation", "isolation: container\n", []string{`m.yaml:1:12: isolation must be none or worktree`}},
+        {"timeout", "timeout: soon\n", []string{`m.yaml:1:10: timeout: invalid duration "soon"`}},
+        {"syntax error", "name: [a\n", []string{"m.yaml: yaml:"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := Parse([]byte(tt.yaml), "m.yaml")
+            if len(tt.errs) == 0 {
+                if err != nil { t.Fatalf("unexpected error: %v", err) }
+                return
+            }
+            if err == nil { t.Fatalf("no error, want %q", tt.errs) }
+            got := err.Error()
+            if n := strings.Count(got, "\n") + 1; n != len(tt.errs) { t.Errorf("%d errors, want %d: %s", n, len(tt.errs), got) }
+            for _, want := range tt.errs {
+                if !strings.Contains(got, want) { t.Errorf("error %q does not contain %q", got, want) }
+            }
+        })
+    }
+}
+
+func TestParseMergeValues(t *testing.T) {
+    m, err := Parse([]byte("x-base: &base\n  network: allow\n  filesystem:\n    read: [./]\npolicies:\n  <<: *base\n  filesystem:\n    write: [./out]\n"), "m.yaml")
+    if err != nil { t.Fatal(err) }
+    if !m.Policies.NetworkAllowed() { t.Error("merged network: allow not applied") }
+    if len(m.Policies.Filesystem.Write) != 1 || len(m.Policies.Filesystem.Read) != 0 { t.Errorf("filesystem = %+v, want the local mapping to override the merged one", m.Policies.Filesystem) }
+}
@@ -0,0 +1,162 @@
+package manifest
+
+import (
+    "fmt"
+    "reflect"
+    "sort"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// validate walks n against t and reports unknown keys and shape mismatches
+// (e.g. a mapping where a list is expected) with line/column positions.
+func validate(n *yaml.Node, t reflect.Type, source, path string) []error {
+    if n.Kind == yaml.AliasNode && n.Alias != nil {
+        return validate(n.Alias, t, source, path)
+    }
+    if n.Tag == "!!null" {
+        return nil
+    }
+    for t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+    errorf := func(n *yaml.Node, format string, args ...interface{}) error {
+        loc := path
+        if loc == "" { loc = "manifest" }
+        return fmt.Errorf("%s:%d:%d: %s: %s", source, n.Line, n.Column, loc, fmt.Sprintf(format, args...))
+    }
+
+    switch t.Kind() {
+    case reflect.Struct:
+        if n.Kind != yaml.MappingNode {
+            return []error{errorf(n, "expected a mapping, got %s", kindName(n))}
+        }
+        fields := yamlFields(t)
+        var errs []error
+        for i := 0; i+1 < len(n.Content); i += 2 {
+            k, v := n.Content[i], n.Content[i+1]
+            if k.Value == "<<" {
+                // merge key: the merged mapping(s) must fit the same schema
+                errs = append(errs, validateMerge(v, t, source, path)...)
+                continue
+            }
+            ft, ok := fields[k.Value]
+            if !ok && path == "" && strings.HasPrefix(k.Value, "x-") {
+                // top-level x-* keys are free-form, e.g. to hold YAML anchors
+                continue
+            }
+            if !ok {
+                msg := fmt.Sprintf("unknown key %q", k.Value)
+                if s := suggest(k.Value, fields); s != "" {
+                    msg += fmt.Sprintf(" (did you mean %q?)", s)
+                }
+                errs = append(errs, errorf(k, "%s", msg))
+                continue
+            }
+            errs = append(errs, validate(v, ft, source, joinPath(path, k.Value))...)
+        }
+        return errs
+    case reflect.Map:
+        if n.Kind != yaml.MappingNode {
+            return []error{errorf(n, "expected a mapping, got %s", kindName(n))}
+        }
+        var errs []error
+        for i := 0; i+1 < len(n.Content); i += 2 {
+            k, v := n.Content[i], n.Content[i+1]
+            if k.Value == "<<" {
+                errs = append(errs, validateMerge(v, t, source, path)...)
+                continue
+            }
+            errs = append(errs, validate(v, t.Elem(), source, joinPath(path, k.Value))...)
+        }
+        return errs
+    case reflect.Slice:
+        if n.Kind != yaml.SequenceNode {
+            return []error{errorf(n, "expected a list, got %s", kindName(n))}
+        }
+        var errs []error
+        for i, item := range n.Content {
+            errs = append(errs, validate(item, t.Elem(), source, fmt.Sprintf("%s[%d]", path, i))...)
+        }
+        return errs
+    default:
+        if n.Kind != yaml.ScalarNode {
+            return []error{errorf(n, "expected a scalar value, got %s", kindName(n))}
+        }
+        return nil
+    }
+}
+
+// validateMerge validates the value of a << key: one mapping, or a list of
+// mappings merged in order.
+func validateMerge(v *yaml.Node, t reflect.Type, source, path string) []error {
+    if v.Kind != yaml.SequenceNode { return validate(v, t, source, path) }
+    var errs []error
+    for _, item := range v.Content {
+        errs = append(errs, validate(item, t, source, path)...)
+    }
+    return errs
+}
+
+// yamlFields maps yaml keys to field types for struct t.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+    out := map[string]reflect.Type{}
+    for i := 0; i < t.NumField(); i++ {
+        f := t.Field(i)
+        if f.PkgPath != "" { continue } // unexported
+        name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+        if name == "-" { continue }
+        if name == "" { name = strings.ToLower(f.Name) }
+        out[name] = f.Type
+    }
+    return out
+}
+
+func joinPath(path, key string) string {
+    if path == "" { return key }
+    return path + "." + key
+}
+
+func kindName(n *yaml.Node) string {
+    switch n.Kind {
+    case yaml.MappingNode:
+        return "a mapping"
+    case yaml.SequenceNode:
+        return "a list"
+    case yaml.ScalarNode:
+        return fmt.Sprintf("scalar %q", n.Value)
+    default:
+        return "an unsupported node"
+    }
+}
+
+// suggest returns the known key closest to key, if it is a likely typo.
+func suggest(key string, fields map[string]reflect.Type) string {
+    names := make([]string, 0, len(fields))
+    for k := range fields { names = append(names, k) }
+    sort.Strings(names)
+    best, bestDist := "", 3
+    for _, name := range names {
+        if d := editDistance(key, name); d < bestDist {
+            best, bestDist = name, d
+        }
+    }
+    return best
+}
+
+func editDistance(a, b string) int {
+    prev := make([]int, len(b)+1)
+    cur := make([]int, len(b)+1)
+    for j := range prev { prev[j] = j }
+    for i := 1; i <= len(a); i++ {
+        cur[0] = i
+        for j := 1; j <= len(b); j++ {
+            cost := 1
+            if a[i-1] == b[j-1] { cost = 0 }
+            cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+        }
+        prev, cur = cur, prev
+    }
+    return prev[len(b)]
+}
@@ -0,0 +1,173 @@
+package mcp
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "io"
+    "sort"
+    "sync"
+)
+
+// ProtocolVersion is the newest MCP revision this server speaks.
+const ProtocolVersion = "2025-03-26"
+
+var supportedVersions = map[string]bool{"2025-03-26": true, "2024-11-05": true}
+
+// JSON-RPC error codes.
+const (
+    codeParseError     = -32700
+    codeInvalidRequest = -32600
+    codeMethodNotFound = -32601
+    codeInvalidParams  = -32602
+)
+
+// Tool is a callable exposed via tools/list and tools/call.
+type Tool struct {
+    Name        string
+    Description string
+    // InputSchema is a JSON Schema object describing the arguments.
+    InputSchema map[string]interface{}
+    // Handler returns the text result. A returned error is reported to the
+    // client as a tool error (isError), not a protocol error.
+    Handler func(args json.RawMessage) (string, error)
+}
+
+// Server is a minimal Model Context Protocol server over newline-delimited
+// JSON-RPC 2.0 (the stdio transport). It only offers tools.
+type Server struct {
+    Name    string
+    Version string
+    tools   map[string]Tool
+    mu      sync.Mutex // guards writes to out
+}
+
+// NewServer creates a server that reports name/version in initialize.
+func NewServer(name, version string) *Server {
+    return &Server{Name: name, Version: version, tools: map[string]Tool{}}
+}
+
+// AddTool registers t, replacing any tool with the same name.
+func (s *Server) AddTool(t Tool) {
+    s.tools[t.Name] = t
+}
+
+type request struct {
+    JSONRPC string          `json:"jsonrpc"`
+    ID      json.RawMessage `json:"id,omitempty"`
+    Method  string          `json:"method"`
+    Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+    JSONRPC string          `json:"jsonrpc"`
+    ID      json.RawMessage `json:"id"`
+    Result  interface{}     `json:"result,omitempty"`
+    Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+    Code    int    `json:"code"`
+    Message string `json:"message"`
+}
+
+// Serve reads requests from in and writes responses to out until in is closed.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+    sc := bufio.NewScanner(in)
+    sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+    for sc.Scan() {
+        line := sc.Bytes()
+        if len(line) == 0 { continue }
+        var req request
+        if err := json.Unmarshal(line, &req); err != nil {
+            s.write(out, response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{codeParseError, err.Error()}})
+            continue
+        }
+        if len(req.ID) == 0 {
+            continue // notification (e.g. notifications/initialized): no reply
+        }
+        result, rerr := s.handle(req)
+        resp := response{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rerr}
+        s.write(out, resp)
+    }
+    return sc.Err()
+}
+
+func (s *Server) write(out io.Writer, resp response) {
+    b, err := json.Marshal(resp)
+    if err != nil {
+        b, _ = json.Marshal(response{JSONRPC: "2.0", ID: resp.ID, Error: &rpcError{codeInvalidRequest, err.Error()}})
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    out.Write(append(b, '\n'))
+}
+
+func (s *Server) handle(req request) (interface{}, *rpcError) {
+    if req.JSONRPC != "2.0" {
+        return nil, &rpcError{codeInvalidRequest, "jsonrpc must be 2.0"}
+    }
+    switch req.Method {
+    case "initialize":
+        var p struct {
+            ProtocolVersion string `json:"protocolVersion"`
+        }
+        _ = json.Unmarshal(req.Params, &p)
+        version := ProtocolVersion
+        if supportedVersions[p.ProtocolVersion] { version = p.ProtocolVersion }
+        return map[string]interface{}{
+            "protocolVersion": version,
+            "capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+            "serverInfo":      map[string]string{"name": s.Name, "version": s.Version},
+        }, nil
+    case "ping":
+        return map[string]interface{}{}, nil
+    case "tools/list":
+        names := make([]string, 0, len(s.tools))
+        for n := range s.tools { names = append(names, n) }
+        sort.Strings(names)
+        list := make([]map[string]interface{}, 0, len(names))
+        for _, n := range names {
+            t := s.tools[n]
+            list = append(list, map[string]interface{}{"name": t.Name, "description": t.Description, "inputSchema": t.InputSchema})
+        }
+        return map[string]interface{}{"tools": list}, nil
+    case "tools/call":
+        var p struct {
+            Name      string          `json:"name"`
+            Arguments json.RawMessage `json:"arguments"`
+        }
+        if err := json.Unmarshal(req.Params, &p); err != nil {
+            return nil, &rpcError{codeInvalidParams, err.Error()}
+        }
+        t, ok := s.tools[p.Name]
+        if !ok {
+            return nil, &rpcError{codeInvalidParams, fmt.Sprintf("unknown tool: %s", p.Name)}
+        }
+        if len(p.Arguments) == 0 { p.Arguments = json.RawMessage("{}") }
+        text, err := t.Handler(p.Arguments)
+        isError := false
+        if err != nil {
+            text = err.Error()
+            isError = true
+        }
+        return map[string]interface{}{
+            "content": []map[string]string{{"type": "text", "text": text}},
+            "isError": isError,
+        }, nil
+    default:
+        return nil, &rpcError{codeMethodNotFound, "method not found: " + req.Method}
+    }
+}
+
+// Schema builds a JSON Schema object from property name -> (type, description)
+// pairs; required lists mandatory properties.
+func Schema(props map[string][2]string, required ...string) map[string]interface{} {
+    p := map[string]interface{}{}
+    for name, td := range props {
+        p[name] = map[string]string{"type": td[0], "description": td[1]}
+    }
+    s := map[string]interface{}{"type": "object", "properties": p}
+    if len(required) > 0 { s["required"] = required }
+    return s
+}
@@ -0,0 +1,80 @@
+package mcp
+
+import (
+    "encoding/json"
+    "errors"
+    "strings"
+    "testing"
+)
+
+func TestServe(t *testing.T) {
+    s := NewServer("test", "1.2.3")
+    s.AddTool(Tool{
+        Name:        "echo",
+        InputSchema: Schema(map[string][2]string{"text": {"string", "Text to echo"}}, "text"),
+        Handler: func(raw json.RawMessage) (string, error) {
+            var a struct {
+                Text string `json:"text"`
+            }
+            if err := json.Unmarshal(raw, &a); err != nil { return "", err }
+            if a.Text == "" { return "", errors.New("text is required") }
+            return a.Text, nil
+        },
+    })
+
+    // Each line is a request; want is its reply, "" for none. An error
+    // without a message only checks the code, as encoding/json's messages
+    // vary between Go releases.
+    tests := []struct {
+        req, want string
+    }{
+        {`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`,
+            `{"jsonrpc":"2.0","id":1,"result":{"capabilities":{"tools":{}},"protocolVersion":"2024-11-05","serverInfo":{"name":"test","version":"1.2.3"}}}`},
+        {`{"jsonrpc":"2.0","id":2,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`,
+            `{"jsonrpc":"2.0","id":2,"result":{"capabilities":{"tools":{}},"protocolVersion":"` + ProtocolVersion + `","serverInfo":{"name":"test","version":"1.2.3"}}}`},
+        {`{"jsonrpc":"2.0","method":"notifications/initialized"}`, ""},
+        {`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"text":"ignored"}}}`, ""},
+        {`{"jsonrpc":"2.0","id":"p","method":"ping"}`, `{"jsonrpc":"2.0","id":"p","result":{}}`},
+        {`{not json`, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700}}`},
+        {`{"jsonrpc":"1.0","id":3,"method":"ping"}`, `{"jsonrpc":"2.0","id":3,"error":{"code":-32600,"message":"jsonrpc must be 2.0"}}`},
+        {`{"jsonrpc":"2.0","id":4,"method":"resources/list"}`, `{"jsonrpc":"2.0","id":4,"error":{"code":-32601,"message":"method not found: resources/list"}}`},
+        {`{"jsonrpc":"2.0","id":5,"method":"tools/list"}`,
+            `{"jsonrpc":"2.0","id":5,"result":{"tools":[{"description":"","inputSchema":{"properties":{"text":{"description":"Text to echo","type":"string"}},"required":["text"],"type":"object"},"name":"echo"}]}}`},
+        {`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`,
+            `{"jsonrpc":"2.0","id":6,"result":{"content":[{"text":"hi","type":"text"}],"isError":false}}`},
+        {`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"echo"}}`,
+            `{"jsonrpc":"2.0","id":7,"result":{"content":[{"text":"text is required","type":"text"}],"isError":true}}`},
+        {`{"jsonrpc":"2.0","id":8,"method":"tools/call","params":{"name":"nope","arguments":{}}}`,
+            `{"jsonrpc":"2.0","id":8,"error":{"code":-32602,"message":"unknown tool: nope"}}`},
+        {`{"jsonrpc":"2.0","id":9,"method":"tools/call","params":[1]}`,
+            `{"jsonrpc":"2.0","id":9,"error":{"code":-32602}}`},
+    }
+    var in strings.Builder
+    var want []string
+    for _, tt := range tests {
+        in.WriteString(tt.req + "\n\n") // blank lines are skipped
+        if tt.want != "" { want = append(want, tt.want) }
+    }
+    var out strings.Builder
+    if err := s.Serve(strings.NewReader(in.String()), &out); err != nil { t.Fatal(err) }
+    got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+    if len(got) != len(want) { t.Fatalf("%d replies, want %d:\n%s", len(got), len(want), out.String()) }
+    for i := range want {
+        if !sameJSON(t, got[i], want[i]) { t.Errorf("reply %d:\n got %s\nwant %s", i, got[i], want[i]) }
+    }
+}
+
+// sameJSON compares reply a with want b ignoring key order, and the error
+// message when b has none.
+func sameJSON(t *testing.T, a, b string) bool {
+    t.Helper()
+    var va, vb map[string]interface{}
+    if err := json.Unmarshal([]byte(a), &va); err != nil { t.Fatalf("reply %s: %v", a, err) }
+    if err := json.Unmarshal([]byte(b), &vb); err != nil { t.Fatalf("want %s: %v", b, err) }
+    ea, _ := va["error"].(map[string]interface{})
+    eb, _ := vb["error"].(map[string]interface{})
+    if ea != nil && eb != nil && eb["message"] == nil { delete(ea, "message") }
+    ja, _ := json.Marshal(va)
+    jb, _ := json.Marshal(vb)
+    return string(ja) == string(jb)
+}
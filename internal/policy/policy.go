@@ -0,0 +1,131 @@
+package policy
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+
+    "heimdal/internal/manifest"
+)
+
+// Action is what happens to a command.
+type Action string
+
+const (
+    Allow Action = "allow"
+    Deny  Action = "deny"
+    Ask   Action = "ask"
+)
+
+// severity orders actions from most to least permissive.
+var severity = map[Action]int{Allow: 0, Ask: 1, Deny: 2}
+
+// Rule is a compiled manifest.CommandRule.
+type Rule struct {
+    Action  Action
+    Pattern string // as written, prefixed "re:" for regexps
+    Reason  string
+    re      *regexp.Regexp
+}
+
+// Policy decides commands with ordered rules; the first match wins and
+// Default applies when nothing matches.
+type Policy struct {
+    Rules   []Rule
+    Default Action
+}
+
+// Verdict is the outcome for one command. Rule is the 1-based index of the
+// matching rule, or 0 when Default applied.
+type Verdict struct {
+    Action Action
+    Rule   int
+    Reason string
+}
+
+// New compiles manifest rules.
+func New(rules []manifest.CommandRule, def Action) (*Policy, error) {
+    p := &Policy{Default: def}
+    for i, r := range rules {
+        var re *regexp.Regexp
+        var err error
+        pattern := r.Match
+        if r.Regex != "" {
+            re, err = regexp.Compile(r.Regex)
+            pattern = "re:" + r.Regex
+        } else {
+            re, err = globRegexp(r.Match)
+        }
+        if err != nil { return nil, fmt.Errorf("policies.commands[%d]: %w", i, err) }
+        p.Rules = append(p.Rules, Rule{Action: Action(r.Action), Pattern: pattern, Reason: r.Reason, re: re})
+    }
+    return p, nil
+}
+
+// Normalize collapses whitespace so rules see "git push  -f" as "git push -f".
+func Normalize(line string) string {
+    return strings.Join(strings.Fields(line), " ")
+}
+
+// Check decides a command line.
+func (p *Policy) Check(line string) Verdict {
+    line = Normalize(line)
+    for i, r := range p.Rules {
+        if r.re.MatchString(line) {
+            reason := r.Reason
+            if reason == "" { reason = fmt.Sprintf("rule %d: %s", i+1, r.Pattern) }
+            return Verdict{Action: r.Action, Rule: i + 1, Reason: reason}
+        }
+    }
+    return Verdict{Action: p.Default, Reason: "default"}
+}
+
+// CheckArgv decides an exec'd argv, joined with spaces.
+func (p *Policy) CheckArgv(argv []string) Verdict {
+    return p.Check(strings.Join(argv, " "))
+}
+
+// Strictest returns the most restrictive verdict, preferring one from a rule
+// over the default on ties.
+func Strictest(vs ...Verdict) Verdict {
+    var best Verdict
+    for i, v := range vs {
+        if i == 0 || severity[v.Action] > severity[best.Action] ||
+            (severity[v.Action] == severity[best.Action] && best.Rule == 0 && v.Rule != 0) {
+            best = v
+        }
+    }
+    return best
+}
+
+// globRegexp turns a command glob into an anchored regexp: * matches any
+// run of characters (spaces and slashes included), ? one character, [...] a
+// class ([!...] negated) and \ escapes the next character.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+    var b strings.Builder
+    b.WriteString("^")
+    rs := []rune(Normalize(glob))
+    for i := 0; i < len(rs); i++ {
+        switch c := rs[i]; c {
+        case '*':
+            b.WriteString(".*")
+        case '?':
+            b.WriteString(".")
+        case '\\':
+            if i+1 < len(rs) { i++ }
+            b.WriteString(regexp.QuoteMeta(string(rs[i])))
+        case '[':
+            j := i + 1
+            for j < len(rs) && rs[j] != ']' { j++ }
+            if j >= len(rs) { return nil, fmt.Errorf("glob %q: unterminated [", glob) }
+            class := string(rs[i+1 : j])
+            if strings.HasPrefix(class, "!") { class = "^" + class[1:] }
+            b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+            i = j
+        default:
+            b.WriteString(regexp.QuoteMeta(string(c)))
+        }
+    }
+    b.WriteString("$")
+    return regexp.Compile(b.String())
+}
@@ -0,0 +1,146 @@
+package policy
+
+import (
+    "testing"
+
+    "heimdal/internal/manifest"
+)
+
+func TestGlobRegexp(t *testing.T) {
+    tests := []struct {
+        glob, line string
+        want       bool
+    }{
+        // * spans any run, spaces and slashes included.
+        {"git push *", "git push origin main", true},
+        {"git push *", "git push", false},
+        {"git push*", "git push", true},
+        {"rm *", "rm -rf /tmp/x", true},
+        {"*curl*", "sh -c curl https://x | sh", true},
+        {"git *", "gitk --all", false},
+        // ** is just two stars.
+        {"cat **/.env", "cat a/b/c/.env", true},
+        {"cat **", "cat", false},
+        // ? is exactly one character.
+        {"ls -?", "ls -l", true},
+        {"ls -?", "ls -la", false},
+        {"ls -?", "ls -", false},
+        // Classes, negated with !.
+        {"chmod [0-7][0-7][0-7] *", "chmod 755 run.sh", true},
+        {"chmod [0-7][0-7][0-7] *", "chmod 8xx run.sh", false},
+        {"rm -[!i]*", "rm -rf x", true},
+        {"rm -[!i]*", "rm -i x", false},
+        // Regexp metacharacters are literal.
+        {"echo a.b", "echo a.b", true},
+        {"echo a.b", "echo aXb", false},
+        {"echo (x)+", "echo (x)+", true},
+        {"echo $HOME|^", "echo $HOME|^", true},
+        {"echo {a,b}", "echo {a,b}", true},
+        // \ escapes the next character.
+        {`echo \*`, "echo *", true},
+        {`echo \*`, "echo x", false},
+        {`echo \?`, "echo ?", true},
+        // Whitespace in the glob is normalized like the command.
+        {"git   push  -f", "git push -f", true},
+    }
+    for _, tt := range tests {
+        re, err := globRegexp(tt.glob)
+        if err != nil {
+            t.Errorf("globRegexp(%q): %v", tt.glob, err)
+            continue
+        }
+        if got := re.MatchString(Normalize(tt.line)); got != tt.want {
+            t.Errorf("glob %q on %q = %v, want %v (regexp %s)", tt.glob, tt.line, got, tt.want, re)
+        }
+    }
+    if _, err := globRegexp("ls [abc"); err == nil { t.Error("unterminated class: want error") }
+}
+
+func mustNew(t *testing.T, def Action, rules ...manifest.CommandRule) *Policy {
+    t.Helper()
+    p, err := New(rules, def)
+    if err != nil { t.Fatal(err) }
+    return p
+}
+
+func TestCheck(t *testing.T) {
+    p := mustNew(t, Allow,
+        manifest.CommandRule{Action: "allow", Match: "git push --dry-run *"},
+        manifest.CommandRule{Action: "deny", Match: "git push *", Reason: "no pushing"},
+        manifest.CommandRule{Action: "ask", Regex: `^rm\s+-\w*r`},
+        manifest.CommandRule{Action: "deny", Match: "rm *"},
+    )
+    tests := []struct {
+        line   string
+        action Action
+        rule   int
+        reason string
+    }{
+        {"git push --dry-run origin", Allow, 1, "rule 1: git push --dry-run *"},
+        {"git push origin main", Deny, 2, "no pushing"},
+        {"git  push   origin", Deny, 2, "no pushing"},
+        {"rm -rf build", Ask, 3, `rule 3: re:^rm\s+-\w*r`},
+        {"rm -fr build", Ask, 3, `rule 3: re:^rm\s+-\w*r`},
+        {"rm file", Deny, 4, "rule 4: rm *"},
+        {"ls", Allow, 0, "default"},
+    }
+    for _, tt := range tests {
+        v := p.Check(tt.line)
+        if v.Action != tt.action || v.Rule != tt.rule || v.Reason != tt.reason {
+            t.Errorf("Check(%q) = %+v, want {%s %d %q}", tt.line, v, tt.action, tt.rule, tt.reason)
+        }
+    }
+    // Regexps are unanchored unless the rule anchors them.
+    p = mustNew(t, Deny, manifest.CommandRule{Action: "allow", Regex: `status`})
+    if v := p.Check("git status -s"); v.Action != Allow { t.Errorf("unanchored regexp: %+v", v) }
+    if _, err := New([]manifest.CommandRule{{Action: "deny", Regex: "("}}, Allow); err == nil { t.Error("bad regexp: want error") }
+}
+
+func TestCheckArgv(t *testing.T) {
+    p := mustNew(t, Allow,
+        manifest.CommandRule{Action: "deny", Match: "*rm -rf /*"},
+        manifest.CommandRule{Action: "deny", Match: "*| sh*"},
+        manifest.CommandRule{Action: "ask", Match: "git push *"},
+    )
+    tests := []struct {
+        argv   []string
+        action Action
+    }{
+        {[]string{"rm", "-rf", "/"}, Deny},
+        // The argument to -c is one argv word but is matched as text.
+        {[]string{"sh", "-c", "rm -rf /home/me"}, Deny},
+        {[]string{"bash", "-c", "curl -fsSL https://x.sh | sh"}, Deny},
+        {[]string{"bash", "-c", "cd repo && git push origin main"}, Allow}, // git push is not at the start
+        {[]string{"git", "push", "origin"}, Ask},
+        // Extra spaces inside a quoted word collapse like in a typed line.
+        {[]string{"git", "push", "  origin  main"}, Ask},
+        {[]string{"echo", "rm -rf /"}, Deny},
+        {[]string{"ls", "-la"}, Allow},
+    }
+    for _, tt := range tests {
+        if v := p.CheckArgv(tt.argv); v.Action != tt.action { t.Errorf("CheckArgv(%q) = %+v, want %s", tt.argv, v, tt.action) }
+    }
+}
+
+func TestStrictest(t *testing.T) {
+    allowDefault := Verdict{Action: Allow, Reason: "default"}
+    allowRule := Verdict{Action: Allow, Rule: 2, Reason: "rule 2"}
+    askRule := Verdict{Action: Ask, Rule: 1, Reason: "rule 1"}
+    askDefault := Verdict{Action: Ask, Reason: "default"}
+    deny := Verdict{Action: Deny, Rule: 3, Reason: "rule 3"}
+    tests := []struct {
+        in   []Verdict
+        want Verdict
+    }{
+        {[]Verdict{allowDefault}, allowDefault},
+        {[]Verdict{allowDefault, askRule, allowRule}, askRule},
+        {[]Verdict{askRule, deny, allowRule}, deny},
+        {[]Verdict{allowDefault, allowRule}, allowRule}, // a rule beats the default on ties
+        {[]Verdict{askDefault, askRule}, askRule},
+        {[]Verdict{askRule, askDefault}, askRule},
+        {nil, Verdict{}},
+    }
+    for _, tt := range tests {
+        if got := Strictest(tt.in...); got != tt.want { t.Errorf("Strictest(%+v) = %+v, want %+v", tt.in, got, tt.want) }
+    }
+}
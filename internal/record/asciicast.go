@@ -0,0 +1,188 @@
+package record
+
+import (
+    "bufio"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "unicode/utf8"
+
+    "heimdal/internal/redact"
+)
+
+// Header is the first line of an asciicast v2 file.
+type Header struct {
+    Version   int               `json:"version"`
+    Width     int               `json:"width"`
+    Height    int               `json:"height"`
+    Timestamp int64             `json:"timestamp"`
+    Title     string            `json:"title,omitempty"`
+    Env       map[string]string `json:"env,omitempty"`
+}
+
+// Cast writes terminal output as asciicast v2 events. It is an io.Writer so
+// it can sit behind an io.MultiWriter next to the real terminal.
+type Cast struct {
+    mu      sync.Mutex
+    f       *os.File
+    w       *bufio.Writer
+    start   time.Time
+    last    time.Time // of the latest output
+    pending []byte // trailing bytes held back: a split rune or a possible secret
+    red     *redact.Redactor
+}
+
+// Create starts a new recording at path.
+func Create(path string, width, height int, title string) (*Cast, error) {
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { return nil, err }
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+    if err != nil { return nil, err }
+    if width <= 0 { width = 80 }
+    if height <= 0 { height = 24 }
+    now := time.Now()
+    h := Header{Version: 2, Width: width, Height: height, Timestamp: now.Unix(), Title: title,
+        Env: map[string]string{"SHELL": os.Getenv("SHELL"), "TERM": os.Getenv("TERM")}}
+    b, err := json.Marshal(h)
+    if err != nil {
+        f.Close()
+        return nil, err
+    }
+    w := bufio.NewWriter(f)
+    w.Write(append(b, '\n'))
+    return &Cast{f: f, w: w, start: now, last: now}, nil
+}
+
+// Redact masks secrets known to r in every event written from now on.
+func (c *Cast) Redact(r *redact.Redactor) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.red = r
+}
+
+// Write records p as an output ("o") event.
+func (c *Cast) Write(p []byte) (int, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if len(p) > 0 { c.last = time.Now() }
+    data := append(c.pending, p...)
+    // Hold back a split multi-byte rune until the rest arrives.
+    cut := len(data)
+    for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+        if utf8.RuneStart(data[i]) {
+            if !utf8.FullRune(data[i:]) { cut = i }
+            break
+        }
+    }
+    // A secret split across writes must be masked as a whole.
+    cut -= c.red.Partial(data[:cut])
+    c.pending = append([]byte(nil), data[cut:]...)
+    if cut > 0 {
+        if err := c.event("o", c.red.String(string(data[:cut]))); err != nil { return 0, err }
+    }
+    return len(p), nil
+}
+
+// LastOutput returns when output was last written, or the recording's
+// start.
+func (c *Cast) LastOutput() time.Time {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.last
+}
+
+// Resize records a terminal resize ("r") event.
+func (c *Cast) Resize(width, height int) error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.event("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+func (c *Cast) event(kind, data string) error {
+    b, err := json.Marshal([]interface{}{time.Since(c.start).Seconds(), kind, data})
+    if err != nil { return err }
+    _, err = c.w.Write(append(b, '\n'))
+    return err
+}
+
+// Close flushes and closes the recording.
+func (c *Cast) Close() error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if len(c.pending) > 0 {
+        _ = c.event("o", c.red.String(string(c.pending)))
+        c.pending = nil
+    }
+    if err := c.w.Flush(); err != nil {
+        c.f.Close()
+        return err
+    }
+    return c.f.Close()
+}
+
+// Path returns the recording file for the n-th run of a session.
+func Path(sessionDir string, run int) string {
+    if run <= 1 { return filepath.Join(sessionDir, "terminal.cast") }
+    return filepath.Join(sessionDir, fmt.Sprintf("terminal-%d.cast", run))
+}
+
+// List returns the recordings in a session dir, ordered by run.
+func List(sessionDir string) ([]string, error) {
+    matches, err := filepath.Glob(filepath.Join(sessionDir, "terminal*.cast"))
+    if err != nil { return nil, err }
+    runOf := func(p string) int {
+        s := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(p), "terminal"), ".cast")
+        n, err := strconv.Atoi(strings.TrimPrefix(s, "-"))
+        if err != nil { return 1 }
+        return n
+    }
+    sort.Slice(matches, func(i, j int) bool { return runOf(matches[i]) < runOf(matches[j]) })
+    return matches, nil
+}
+
+// ReplayOptions control playback speed.
+type ReplayOptions struct {
+    Speed   float64       // 1 is original speed; 2 is twice as fast
+    MaxIdle time.Duration // cap on pauses between events; 0 means no cap
+}
+
+// Replay plays the recording at path to w with its original timing.
+func Replay(path string, w io.Writer, opt ReplayOptions) error {
+    f, err := os.Open(path)
+    if err != nil { return err }
+    defer f.Close()
+    if opt.Speed <= 0 { opt.Speed = 1 }
+
+    s := bufio.NewScanner(f)
+    s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+    if !s.Scan() {
+        if err := s.Err(); err != nil { return err }
+        return errors.New("empty recording")
+    }
+    var h Header
+    if err := json.Unmarshal(s.Bytes(), &h); err != nil || h.Version != 2 {
+        return fmt.Errorf("%s: not an asciicast v2 recording", path)
+    }
+    prev := 0.0
+    for s.Scan() {
+        var ev []interface{}
+        if err := json.Unmarshal(s.Bytes(), &ev); err != nil || len(ev) != 3 { continue }
+        t, _ := ev[0].(float64)
+        kind, _ := ev[1].(string)
+        data, _ := ev[2].(string)
+        if kind != "o" { continue }
+        wait := time.Duration((t - prev) / opt.Speed * float64(time.Second))
+        if opt.MaxIdle > 0 && wait > opt.MaxIdle { wait = opt.MaxIdle }
+        if wait > 0 { time.Sleep(wait) }
+        prev = t
+        if _, err := io.WriteString(w, data); err != nil { return err }
+    }
+    return s.Err()
+}
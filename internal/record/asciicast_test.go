@@ -0,0 +1,41 @@
+package record
+
+import (
+    "bufio"
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "heimdal/internal/redact"
+)
+
+func TestCastRedactsSplitSecrets(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "terminal.cast")
+    c, err := Create(path, 80, 24, "test")
+    if err != nil { t.Fatal(err) }
+    r, err := redact.New(nil)
+    if err != nil { t.Fatal(err) }
+    r.Add("hunter2hunter2", "o")   // "o" is below MinLen and ignored
+    r.AddEnv([]string{"PASS_MODE=output"}) // a plain word, not guessed a secret
+    c.Redact(r)
+    for _, chunk := range []string{"output: hunt", "er2hunter2\r\n", "done\r\n"} {
+        if _, err := c.Write([]byte(chunk)); err != nil { t.Fatal(err) }
+    }
+    if err := c.Close(); err != nil { t.Fatal(err) }
+
+    f, err := os.Open(path)
+    if err != nil { t.Fatal(err) }
+    defer f.Close()
+    sc := bufio.NewScanner(f)
+    sc.Scan() // header
+    var out strings.Builder
+    for sc.Scan() {
+        var ev []interface{}
+        if err := json.Unmarshal(sc.Bytes(), &ev); err != nil || len(ev) != 3 { t.Fatalf("bad event %s: %v", sc.Text(), err) }
+        if ev[1] != "o" { t.Errorf("event kind = %v", ev[1]) }
+        out.WriteString(ev[2].(string))
+    }
+    if want := "output: [REDACTED]\r\ndone\r\n"; out.String() != want { t.Errorf("output = %q, want %q", out.String(), want) }
+}
@@ -0,0 +1,29 @@
+package record
+
+import (
+    "errors"
+    "os/exec"
+    "syscall"
+)
+
+// ExitStatus maps the error returned by Run to a shell-style exit code:
+// the child's own code, 128+N when it died from signal N, 127 when the
+// command was not found, 126 when it could not be started and ExitTimeout
+// when a timeout stopped it. sig names the fatal signal, if any.
+func ExitStatus(err error) (code int, sig string) {
+    if err == nil { return 0, "" }
+    var te *TimeoutError
+    if errors.As(err, &te) {
+        _, sig = ExitStatus(te.Err)
+        return ExitTimeout, sig
+    }
+    var ee *exec.ExitError
+    if errors.As(err, &ee) {
+        if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+            return 128 + int(ws.Signal()), ws.Signal().String()
+        }
+        return ee.ExitCode(), ""
+    }
+    if errors.Is(err, exec.ErrNotFound) { return 127, "" }
+    return 126, ""
+}
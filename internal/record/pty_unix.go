@@ -0,0 +1,155 @@
+//go:build !windows
+
+package record
+
+import (
+    "errors"
+    "io"
+    "os"
+    "os/exec"
+    "os/signal"
+    "sync"
+    "syscall"
+    "time"
+
+    "github.com/creack/pty"
+    "golang.org/x/term"
+)
+
+// Run starts cmd with its output captured into cast. When stdin is a terminal
+// the child runs under a pseudo-terminal so full-screen TUIs keep working;
+// otherwise stdout/stderr are teed into the recording. Either way the child
+// gets its own process group and SIGINT/SIGTERM/SIGHUP/SIGWINCH received by
+// heimdal are forwarded to it. started, if set, is called with the child's
+// pid once it runs.
+func Run(cmd *exec.Cmd, cast *Cast, started func(pid int)) error {
+    stdin := int(os.Stdin.Fd())
+    if !term.IsTerminal(stdin) {
+        return runPiped(cmd, cast, started)
+    }
+
+    ws, err := pty.GetsizeFull(os.Stdin)
+    if err != nil { ws = &pty.Winsize{Rows: 24, Cols: 80} }
+    ptmx, err := pty.StartWithSize(cmd, ws)
+    if err != nil { return err }
+    defer ptmx.Close()
+    if started != nil { started(cmd.Process.Pid) }
+    // The pty session makes the child a process group leader. SIGWINCH is
+    // delivered by the pty itself on resize, below.
+    defer forwardSignals(cmd.Process.Pid, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)()
+
+    winch := make(chan os.Signal, 1)
+    signal.Notify(winch, syscall.SIGWINCH)
+    defer signal.Stop(winch)
+    go func() {
+        for range winch {
+            if sz, err := pty.GetsizeFull(os.Stdin); err == nil {
+                _ = pty.Setsize(ptmx, sz)
+                _ = cast.Resize(int(sz.Cols), int(sz.Rows))
+            }
+        }
+    }()
+
+    if state, err := term.MakeRaw(stdin); err == nil {
+        defer term.Restore(stdin, state)
+    }
+    defer forwardInput(ptmx)()
+
+    // Reading the pty master fails with EIO once the child side is closed.
+    // A background process the app left behind can keep it open, though, so
+    // once the app exits the output is drained for at most drainTimeout. A
+    // copy still running then is detached: it keeps reading, so the holder
+    // does not block on a full pty, but writes nothing more.
+    out := &gatedWriter{w: io.MultiWriter(os.Stdout, cast)}
+    copied := make(chan error, 1)
+    go func() {
+        _, err := io.Copy(out, ptmx)
+        copied <- err
+    }()
+    waitErr := cmd.Wait()
+    var copyErr error
+    select {
+    case copyErr = <-copied:
+    case <-time.After(drainTimeout):
+        out.close()
+    }
+    if waitErr != nil { return waitErr }
+    var pe *os.PathError
+    if copyErr != nil && !(errors.As(copyErr, &pe) && errors.Is(pe.Err, syscall.EIO)) {
+        return copyErr
+    }
+    return nil
+}
+
+// gatedWriter discards writes once closed, so a detached copy cannot write
+// into a finished recording.
+type gatedWriter struct {
+    mu     sync.Mutex
+    w      io.Writer
+    closed bool
+}
+
+func (g *gatedWriter) Write(p []byte) (int, error) {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    if g.closed { return len(p), nil }
+    return g.w.Write(p)
+}
+
+func (g *gatedWriter) close() {
+    g.mu.Lock()
+    g.closed = true
+    g.mu.Unlock()
+}
+
+func runPiped(cmd *exec.Cmd, cast *Cast, started func(pid int)) error {
+    cmd.Stdin = os.Stdin
+    cmd.Stdout = io.MultiWriter(os.Stdout, cast)
+    cmd.Stderr = io.MultiWriter(os.Stderr, cast)
+    if cmd.SysProcAttr == nil { cmd.SysProcAttr = &syscall.SysProcAttr{} }
+    cmd.SysProcAttr.Setpgid = true
+    // Background processes holding the output pipes must not keep Wait
+    // from returning either.
+    cmd.WaitDelay = drainTimeout
+    if err := cmd.Start(); err != nil { return err }
+    if started != nil { started(cmd.Process.Pid) }
+    defer forwardSignals(cmd.Process.Pid, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGWINCH)()
+    if err := cmd.Wait(); !errors.Is(err, exec.ErrWaitDelay) { return err }
+    return nil
+}
+
+// forwardSignals relays sigs to the process group led by pid until the
+// returned stop func is called.
+func forwardSignals(pid int, sigs ...os.Signal) func() {
+    ch := make(chan os.Signal, 4)
+    signal.Notify(ch, sigs...)
+    done := make(chan struct{})
+    go func() {
+        for {
+            select {
+            case sig := <-ch:
+                _ = syscall.Kill(-pid, sig.(syscall.Signal))
+            case <-done:
+                return
+            }
+        }
+    }()
+    return func() {
+        signal.Stop(ch)
+        close(done)
+    }
+}
+
+// terminate is the polite stop signal sent when a run times out.
+var terminate os.Signal = syscall.SIGTERM
+
+// signalGroup sends sig to the process group led by pid.
+func signalGroup(pid int, sig os.Signal) {
+    _ = syscall.Kill(-pid, sig.(syscall.Signal))
+}
+
+// Size reports the current terminal size, or 80x24 when not on a terminal.
+func Size() (int, int) {
+    if w, h, err := term.GetSize(int(os.Stdout.Fd())); err == nil { return w, h }
+    return 80, 24
+}
@@ -0,0 +1,65 @@
+//go:build !windows
+
+package record
+
+import (
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/creack/pty"
+)
+
+// TestRunBackgroundChild checks that Run returns when the app exits even
+// though a process it started in the background still holds the pty.
+func TestRunBackgroundChild(t *testing.T) {
+    // Run only uses a pty when stdin is a terminal.
+    master, tty, err := pty.Open()
+    if err != nil { t.Skipf("no pty: %v", err) }
+    defer master.Close()
+    defer tty.Close()
+    stdin := os.Stdin
+    os.Stdin = tty
+    defer func() { os.Stdin = stdin }()
+
+    path := filepath.Join(t.TempDir(), "terminal.cast")
+    cast, err := Create(path, 80, 24, "test")
+    if err != nil { t.Fatal(err) }
+    cmd := exec.Command("sh", "-c", "trap '' HUP; sleep 30 & echo started")
+    start := time.Now()
+    err = Run(cmd, cast, nil)
+    elapsed := time.Since(start)
+    cast.Close()
+    if err != nil { t.Fatal(err) }
+    if elapsed > 10*time.Second { t.Fatalf("Run waited %v for the background child", elapsed) }
+    b, err := os.ReadFile(path)
+    if err != nil { t.Fatal(err) }
+    if !strings.Contains(string(b), "started") { t.Errorf("output before exit was lost:\n%s", b) }
+}
+
+// TestRunPipedBackgroundChild is the same without a terminal, where the
+// background child holds the output pipes instead.
+func TestRunPipedBackgroundChild(t *testing.T) {
+    devnull, err := os.Open(os.DevNull)
+    if err != nil { t.Fatal(err) }
+    defer devnull.Close()
+    stdin := os.Stdin
+    os.Stdin = devnull
+    defer func() { os.Stdin = stdin }()
+
+    path := filepath.Join(t.TempDir(), "terminal.cast")
+    cast, err := Create(path, 80, 24, "test")
+    if err != nil { t.Fatal(err) }
+    start := time.Now()
+    err = Run(exec.Command("sh", "-c", "sleep 30 & echo started"), cast, nil)
+    elapsed := time.Since(start)
+    cast.Close()
+    if err != nil { t.Fatal(err) }
+    if elapsed > 10*time.Second { t.Fatalf("Run waited %v for the background child", elapsed) }
+    b, err := os.ReadFile(path)
+    if err != nil { t.Fatal(err) }
+    if !strings.Contains(string(b), "started") { t.Errorf("output before exit was lost:\n%s", b) }
+}
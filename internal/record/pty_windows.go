@@ -0,0 +1,44 @@
+//go:build windows
+
+package record
+
+import (
+    "errors"
+    "io"
+    "os"
+    "os/exec"
+
+    "golang.org/x/term"
+)
+
+// Run starts cmd with stdout/stderr teed into cast. Windows has no PTY
+// support here, so output is captured without terminal emulation. started,
+// if set, is called with the child's pid once it runs.
+func Run(cmd *exec.Cmd, cast *Cast, started func(pid int)) error {
+    return runPiped(cmd, cast, started)
+}
+
+func runPiped(cmd *exec.Cmd, cast *Cast, started func(pid int)) error {
+    cmd.Stdin = os.Stdin
+    cmd.Stdout = io.MultiWriter(os.Stdout, cast)
+    cmd.Stderr = io.MultiWriter(os.Stderr, cast)
+    cmd.WaitDelay = drainTimeout
+    if err := cmd.Start(); err != nil { return err }
+    if started != nil { started(cmd.Process.Pid) }
+    if err := cmd.Wait(); !errors.Is(err, exec.ErrWaitDelay) { return err }
+    return nil
+}
+
+// terminate stops a timed-out run; Windows has no SIGTERM to send.
+var terminate = os.Kill
+
+// signalGroup kills the process pid; sig is always a kill here.
+func signalGroup(pid int, sig os.Signal) {
+    if p, err := os.FindProcess(pid); err == nil { _ = p.Kill() }
+}
+
+// Size reports the current terminal size, or 80x24 when not on a terminal.
+func Size() (int, int) {
+    if w, h, err := term.GetSize(int(os.Stdout.Fd())); err == nil { return w, h }
+    return 80, 24
+}
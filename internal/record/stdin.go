@@ -0,0 +1,71 @@
+package record
+
+import (
+    "io"
+    "os"
+    "sync"
+)
+
+// Terminal input is read by one goroutine for the life of the process and
+// handed out in chunks. A run forwards it to the app only until the run
+// ends, so input typed at a prompt shown afterwards goes to the prompt, not
+// to a copy still blocked reading for the old pty.
+
+var (
+    stdinOnce   sync.Once
+    stdinChunks chan []byte
+)
+
+func stdinPump() <-chan []byte {
+    stdinOnce.Do(func() {
+        stdinChunks = make(chan []byte)
+        go func() {
+            for {
+                buf := make([]byte, 4096)
+                n, err := os.Stdin.Read(buf)
+                if n > 0 { stdinChunks <- buf[:n] }
+                if err != nil {
+                    close(stdinChunks)
+                    return
+                }
+            }
+        }()
+    })
+    return stdinChunks
+}
+
+// Stdin reads standard input through the same pump as Run. Prompts shown
+// after a run must read from it rather than os.Stdin.
+var Stdin io.Reader = &pumpReader{}
+
+type pumpReader struct{ pending []byte }
+
+func (r *pumpReader) Read(p []byte) (int, error) {
+    if len(r.pending) == 0 {
+        b, ok := <-stdinPump()
+        if !ok { return 0, io.EOF }
+        r.pending = b
+    }
+    n := copy(p, r.pending)
+    r.pending = r.pending[n:]
+    return n, nil
+}
+
+// forwardInput copies terminal input to w until the returned stop func is
+// called.
+func forwardInput(w io.Writer) (stop func()) {
+    done := make(chan struct{})
+    go func() {
+        in := stdinPump()
+        for {
+            select {
+            case b, ok := <-in:
+                if !ok { return }
+                if _, err := w.Write(b); err != nil { return }
+            case <-done:
+                return
+            }
+        }
+    }()
+    return func() { close(done) }
+}
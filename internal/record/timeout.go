@@ -0,0 +1,103 @@
+package record
+
+import (
+    "fmt"
+    "os"
+    "sync"
+    "time"
+)
+
+// ExitTimeout is the exit code of a run stopped by a timeout, as with
+// coreutils timeout(1).
+const ExitTimeout = 124
+
+// DefaultGrace is how long a timed-out app has between SIGTERM and SIGKILL.
+const DefaultGrace = 10 * time.Second
+
+// drainTimeout is how long Run still reads output after the app exits, in
+// case processes it left behind hold the pty or pipes open.
+const drainTimeout = 200 * time.Millisecond
+
+// Timeouts stop a run that takes too long or stops producing output.
+// Zero durations are off.
+type Timeouts struct {
+    Total time.Duration // wall clock
+    Idle  time.Duration // without output
+    Grace time.Duration // from SIGTERM to SIGKILL; DefaultGrace if 0
+}
+
+// On reports whether any timeout is set.
+func (t Timeouts) On() bool { return t.Total > 0 || t.Idle > 0 }
+
+// TimeoutError is the result of a run stopped by Timeouts.
+type TimeoutError struct {
+    Idle  bool          // stopped for lack of output rather than total time
+    After time.Duration // the timeout that fired
+    Err   error         // what the run returned once stopped
+}
+
+func (e *TimeoutError) Error() string {
+    if e.Idle { return fmt.Sprintf("no output for %s", e.After) }
+    return fmt.Sprintf("timed out after %s", e.After)
+}
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// Watch enforces t on the process group led by pid, judging idleness by the
+// output written to cast. When a timeout fires the group gets SIGTERM, then
+// SIGKILL if it is still running after the grace period. The returned stop
+// func ends the watch and, if it fired, wraps the run's err in a
+// *TimeoutError.
+func Watch(pid int, cast *Cast, t Timeouts) (stop func(err error) error) {
+    if t.Grace <= 0 { t.Grace = DefaultGrace }
+    done := make(chan struct{})
+    var mu sync.Mutex
+    var fired *TimeoutError
+    go func() {
+        start := time.Now()
+        for {
+            now := time.Now()
+            var wait time.Duration = -1
+            if t.Total > 0 {
+                left := start.Add(t.Total).Sub(now)
+                if left <= 0 {
+                    mu.Lock()
+                    fired = &TimeoutError{After: t.Total}
+                    mu.Unlock()
+                    break
+                }
+                wait = left
+            }
+            if t.Idle > 0 {
+                left := cast.LastOutput().Add(t.Idle).Sub(now)
+                if left <= 0 {
+                    mu.Lock()
+                    fired = &TimeoutError{Idle: true, After: t.Idle}
+                    mu.Unlock()
+                    break
+                }
+                if wait < 0 || left < wait { wait = left }
+            }
+            select {
+            case <-done:
+                return
+            case <-time.After(wait):
+            }
+        }
+        signalGroup(pid, terminate)
+        select {
+        case <-done:
+        case <-time.After(t.Grace):
+            signalGroup(pid, os.Kill)
+        }
+    }()
+    var once sync.Once
+    return func(err error) error {
+        once.Do(func() { close(done) })
+        mu.Lock()
+        defer mu.Unlock()
+        if fired == nil { return err }
+        fired.Err = err
+        return fired
+    }
+}
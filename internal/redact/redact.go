@@ -0,0 +1,176 @@
+package redact
+
+import (
+    "fmt"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// Mask replaces every redacted value.
+const Mask = "[REDACTED]"
+
+// MinLen is the shortest value Add accepts; shorter ones would mangle
+// ordinary text.
+const MinLen = 4
+
+// DefaultPatterns match common API token formats and always apply.
+var DefaultPatterns = []string{
+    `sk-[A-Za-z0-9_-]{20,}`,           // OpenAI, Anthropic (sk-ant-...)
+    `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,   // AWS access key IDs
+    `\bgh[pousr]_[A-Za-z0-9]{36,}`,    // GitHub tokens
+    `\bgithub_pat_[A-Za-z0-9_]{22,}`,  // GitHub fine-grained tokens
+    `\bxox[abprs]-[A-Za-z0-9-]{10,}`,  // Slack tokens
+    `\bAIza[0-9A-Za-z_-]{35}`,         // Google API keys
+}
+
+// maxHold caps how much trailing output Partial holds back for a pattern.
+const maxHold = 512
+
+// Redactor replaces known secret values and token-shaped strings with Mask.
+// It is safe for concurrent use, and a nil *Redactor leaves text unchanged.
+type Redactor struct {
+    mu       sync.RWMutex
+    values   []string // longest first, so a value wins over its prefixes
+    patterns []*regexp.Regexp
+}
+
+// New compiles DefaultPatterns plus extra patterns (RE2 syntax).
+func New(extra []string) (*Redactor, error) {
+    r := &Redactor{}
+    for _, p := range append(append([]string{}, DefaultPatterns...), extra...) {
+        re, err := regexp.Compile(p)
+        if err != nil { return nil, fmt.Errorf("redact pattern %q: %w", p, err) }
+        r.patterns = append(r.patterns, re)
+    }
+    return r, nil
+}
+
+// CheckPatterns validates a whitespace-separated list of patterns, as given
+// in the redact_patterns setting.
+func CheckPatterns(list string) error {
+    for _, p := range strings.Fields(list) {
+        if _, err := regexp.Compile(p); err != nil { return fmt.Errorf("%q: %v", p, err) }
+    }
+    return nil
+}
+
+// Add registers secret values to redact. Values shorter than MinLen are
+// ignored. Writers redact text before encoding it, so only the raw form of
+// a value is kept.
+func (r *Redactor) Add(values ...string) {
+    if r == nil { return }
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, v := range values {
+        if len(v) >= MinLen && !contains(r.values, v) { r.values = append(r.values, v) }
+    }
+    sort.SliceStable(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+}
+
+func contains(list []string, s string) bool {
+    for _, v := range list {
+        if v == s { return true }
+    }
+    return false
+}
+
+// String returns s with every secret value and pattern match masked.
+func (r *Redactor) String(s string) string {
+    if r == nil { return s }
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    for _, v := range r.values {
+        if strings.Contains(s, v) { s = strings.ReplaceAll(s, v, Mask) }
+    }
+    for _, re := range r.patterns {
+        s = re.ReplaceAllLiteralString(s, Mask)
+    }
+    return s
+}
+
+// Bytes is String for byte slices.
+func (r *Redactor) Bytes(b []byte) []byte {
+    if r == nil { return b }
+    return []byte(r.String(string(b)))
+}
+
+// Partial returns how many trailing bytes of b might begin a secret that
+// continues in the next chunk of a stream: the longest suffix that is a
+// prefix of a known value, or a trailing run of token characters. Stream
+// writers hold those bytes back until more data arrives.
+func (r *Redactor) Partial(b []byte) int {
+    if r == nil { return 0 }
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    s := string(b)
+    hold := 0
+    for _, v := range r.values {
+        for k := min(len(v)-1, len(s)); k > hold; k-- {
+            if strings.HasSuffix(s, v[:k]) {
+                hold = k
+                break
+            }
+        }
+    }
+    if len(r.patterns) > 0 {
+        run := 0
+        for run < len(s) && run < maxHold && isTokenByte(s[len(s)-1-run]) { run++ }
+        if run < maxHold && run > hold { hold = run }
+    }
+    return hold
+}
+
+func isTokenByte(c byte) bool {
+    return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+var sensitiveKey = regexp.MustCompile(`(?i)(KEY|TOKEN|SECRET|PASS|CREDENTIAL)`)
+
+// SensitiveKey reports whether an env var name suggests its value is a
+// credential, e.g. GEMINI_API_KEY or GITHUB_TOKEN.
+func SensitiveKey(name string) bool {
+    return sensitiveKey.MatchString(name)
+}
+
+// MinGuessLen is the shortest value AddSensitive accepts.
+const MinGuessLen = 8
+
+// LooksSecret reports whether a value found under a sensitive-looking name
+// is worth masking. Such names also hold plain settings (KEYBOARD=us,
+// GPG_KEY_DIR=/home/me/.gnupg, PASS_MODE=session, KEYCLOAK_URL=https://...),
+// and masking those would mangle every line they appear in. It takes a
+// value of MinGuessLen or more, not a path or credential-free URL, that
+// mixes letters with digits or symbols, or is a long mixed-case run.
+func LooksSecret(v string) bool {
+    if len(v) < MinGuessLen || strings.ContainsAny(v, " \t\n") { return false }
+    if strings.HasPrefix(v, "/") || strings.HasPrefix(v, "~") || strings.HasPrefix(v, "./") { return false }
+    if i := strings.Index(v, "://"); i > 0 && !strings.Contains(v[i:], "@") { return false }
+    var lower, upper, others bool
+    for _, c := range v {
+        switch {
+        case c >= 'a' && c <= 'z':
+            lower = true
+        case c >= 'A' && c <= 'Z':
+            upper = true
+        case c != '_' && c != '-' && c != '.':
+            others = true
+        }
+    }
+    if !lower && !upper { return false } // numbers, versions
+    return others || lower && upper && len(v) >= 20
+}
+
+// AddSensitive registers value if name looks sensitive and the value looks
+// like a secret. Values the user declared secret go through Add instead.
+func (r *Redactor) AddSensitive(name, value string) {
+    if SensitiveKey(name) && LooksSecret(value) { r.Add(value) }
+}
+
+// AddEnv calls AddSensitive for each env entry ("KEY=value").
+func (r *Redactor) AddEnv(env []string) {
+    for _, kv := range env {
+        if i := strings.IndexByte(kv, '='); i > 0 { r.AddSensitive(kv[:i], kv[i+1:]) }
+    }
+}
@@ -0,0 +1,227 @@
+//go:build linux
+
+package sandbox
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "syscall"
+    "time"
+)
+
+// cpuPeriod is the cpu.max period in microseconds.
+const cpuPeriod = 100000
+
+// Cgroup is a cgroup v2 group created for one wrapped app, below the
+// cgroup heimdal itself runs in.
+type Cgroup struct {
+    Path string
+    dir  *os.File
+}
+
+// NewCgroup creates a child cgroup named name with limits l. It needs the
+// controllers for l delegated to heimdal's cgroup (e.g. under
+// `systemd-run --user --scope -p Delegate=yes`); otherwise it says why not.
+func NewCgroup(name string, l Limits) (*Cgroup, error) {
+    mount, err := cgroup2Mount()
+    if err != nil { return nil, err }
+    rel, err := ownCgroup()
+    if err != nil { return nil, err }
+    parent := filepath.Join(mount, rel)
+
+    var need []string
+    if l.MemoryMax > 0 { need = append(need, "memory") }
+    if l.CPUMax > 0 { need = append(need, "cpu") }
+    if l.PidsMax > 0 { need = append(need, "pids") }
+    if l.IOWeight > 0 { need = append(need, "io") }
+    avail, err := readFields(filepath.Join(parent, "cgroup.controllers"))
+    if err != nil { return nil, err }
+    for _, c := range need {
+        if !avail[c] { return nil, fmt.Errorf("controller %s is not delegated to %s", c, parent) }
+    }
+    if err := enableControllers(parent, need); err != nil { return nil, err }
+
+    path := filepath.Join(parent, name)
+    if err := os.Mkdir(path, 0o755); err != nil && !errors.Is(err, os.ErrExist) { return nil, err }
+    c := &Cgroup{Path: path}
+    set := func(file, value string) error {
+        if err := os.WriteFile(filepath.Join(path, file), []byte(value), 0o644); err != nil {
+            c.Remove()
+            return fmt.Errorf("cgroup: set %s: %w", file, err)
+        }
+        return nil
+    }
+    if l.MemoryMax > 0 {
+        if err := set("memory.max", strconv.FormatInt(l.MemoryMax, 10)); err != nil { return nil, err }
+    }
+    if l.CPUMax > 0 {
+        quota := int64(l.CPUMax * cpuPeriod)
+        if quota < 1000 { quota = 1000 } // the kernel's minimum
+        if err := set("cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod)); err != nil { return nil, err }
+    }
+    if l.PidsMax > 0 {
+        if err := set("pids.max", strconv.Itoa(l.PidsMax)); err != nil { return nil, err }
+    }
+    if l.IOWeight > 0 {
+        if err := set("io.weight", fmt.Sprintf("default %d", l.IOWeight)); err != nil { return nil, err }
+    }
+    c.dir, err = os.Open(path)
+    if err != nil {
+        c.Remove()
+        return nil, err
+    }
+    if err := c.probe(); err != nil {
+        c.Remove()
+        return nil, fmt.Errorf("cannot start processes in a cgroup (CLONE_INTO_CGROUP needs Linux 5.7 and clone3): %w", err)
+    }
+    return c, nil
+}
+
+// probe checks that the kernel can start a process directly in the group,
+// as Attach needs. It forks into the group and execs "/", which always fails
+// with EACCES; any other error comes from clone3 itself (ENOSYS before Linux
+// 5.7 or under seccomp filters that block it).
+func (c *Cgroup) probe() error {
+    attr := &os.ProcAttr{Sys: &syscall.SysProcAttr{UseCgroupFD: true, CgroupFD: int(c.dir.Fd())}}
+    p, err := os.StartProcess("/", []string{"/"}, attr)
+    if err == nil {
+        p.Kill()
+        p.Wait()
+        return nil
+    }
+    if errors.Is(err, syscall.EACCES) { return nil }
+    return err
+}
+
+// enableControllers turns on need in parent's subtree_control. A cgroup with
+// processes of its own cannot do that, so heimdal first moves itself into a
+// leaf; if other processes share the cgroup it gives up and moves back.
+func enableControllers(parent string, need []string) error {
+    control := filepath.Join(parent, "cgroup.subtree_control")
+    enabled, err := readFields(control)
+    if err != nil { return err }
+    var add []string
+    for _, c := range need {
+        if !enabled[c] { add = append(add, "+"+c) }
+    }
+    if len(add) == 0 { return nil }
+    write := func() error { return os.WriteFile(control, []byte(strings.Join(add, " ")), 0o644) }
+    err = write()
+    if err == nil || !errors.Is(err, syscall.EBUSY) {
+        if err != nil { return fmt.Errorf("enable %s in %s: %w", strings.Join(add, " "), parent, err) }
+        return nil
+    }
+    leaf := filepath.Join(parent, "heimdal")
+    if err := os.Mkdir(leaf, 0o755); err != nil && !errors.Is(err, os.ErrExist) { return err }
+    self := []byte(strconv.Itoa(os.Getpid()))
+    if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), self, 0o644); err != nil {
+        return fmt.Errorf("move heimdal into %s: %w", leaf, err)
+    }
+    if err := write(); err != nil {
+        _ = os.WriteFile(filepath.Join(parent, "cgroup.procs"), self, 0o644)
+        _ = os.Remove(leaf)
+        return fmt.Errorf("%s has other processes; run heimdal in its own delegated cgroup", parent)
+    }
+    return nil
+}
+
+// Attach makes cmd start inside the cgroup (CLONE_INTO_CGROUP), so the app
+// cannot fork before it is contained.
+func (c *Cgroup) Attach(cmd *exec.Cmd) {
+    if cmd.SysProcAttr == nil { cmd.SysProcAttr = &syscall.SysProcAttr{} }
+    cmd.SysProcAttr.UseCgroupFD = true
+    cmd.SysProcAttr.CgroupFD = int(c.dir.Fd())
+}
+
+// Usage reads the group's peak memory, CPU time and OOM kills. memory.peak
+// needs Linux 5.19; older kernels report 0.
+func (c *Cgroup) Usage() Usage {
+    u := Usage{Source: "cgroup"}
+    if b, err := os.ReadFile(filepath.Join(c.Path, "memory.peak")); err == nil {
+        u.PeakMemory, _ = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+    }
+    if v, ok := readKey(filepath.Join(c.Path, "cpu.stat"), "usage_usec"); ok {
+        u.CPU = time.Duration(v) * time.Microsecond
+    }
+    if v, ok := readKey(filepath.Join(c.Path, "memory.events"), "oom_kill"); ok {
+        u.OOMKills = int(v)
+    }
+    return u
+}
+
+// Remove deletes the cgroup. It fails, harmlessly, while processes that
+// outlived the app are still inside.
+func (c *Cgroup) Remove() error {
+    if c.dir != nil { c.dir.Close() }
+    return os.Remove(c.Path)
+}
+
+// cgroup2Mount finds where the cgroup v2 hierarchy is mounted.
+func cgroup2Mount() (string, error) {
+    f, err := os.Open("/proc/self/mountinfo")
+    if err != nil { return "", err }
+    defer f.Close()
+    sc := bufio.NewScanner(f)
+    for sc.Scan() {
+        // ... mount-point ... - fstype source options
+        fields := strings.Fields(sc.Text())
+        for i, fld := range fields {
+            if fld == "-" && i+1 < len(fields) && fields[i+1] == "cgroup2" && len(fields) > 4 {
+                return fields[4], nil
+            }
+        }
+    }
+    return "", errors.New("no cgroup v2 hierarchy mounted")
+}
+
+// ownCgroup returns heimdal's cgroup v2 path, relative to the mount.
+func ownCgroup() (string, error) {
+    b, err := os.ReadFile("/proc/self/cgroup")
+    if err != nil { return "", err }
+    for _, line := range strings.Split(string(b), "\n") {
+        if rest, ok := strings.CutPrefix(line, "0::"); ok { return rest, nil }
+    }
+    return "", errors.New("not in a cgroup v2 hierarchy")
+}
+
+// readFields returns the space-separated words of a cgroup file as a set.
+func readFields(path string) (map[string]bool, error) {
+    b, err := os.ReadFile(path)
+    if err != nil { return nil, err }
+    set := map[string]bool{}
+    for _, f := range strings.Fields(string(b)) { set[f] = true }
+    return set, nil
+}
+
+// readKey reads the value of key from a flat-keyed cgroup file.
+func readKey(path, key string) (int64, bool) {
+    b, err := os.ReadFile(path)
+    if err != nil { return 0, false }
+    for _, line := range strings.Split(string(b), "\n") {
+        if k, v, ok := strings.Cut(line, " "); ok && k == key {
+            n, err := strconv.ParseInt(v, 10, 64)
+            return n, err == nil
+        }
+    }
+    return 0, false
+}
+
+// userProcesses counts the processes owned by our real uid.
+func userProcesses() int {
+    entries, err := os.ReadDir("/proc")
+    if err != nil { return 0 }
+    uid := os.Getuid()
+    n := 0
+    for _, e := range entries {
+        if _, err := strconv.Atoi(e.Name()); err != nil { continue }
+        var st syscall.Stat_t
+        if syscall.Stat(filepath.Join("/proc", e.Name()), &st) == nil && int(st.Uid) == uid { n++ }
+    }
+    return n
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package sandbox
+
+import (
+    "fmt"
+    "os/exec"
+    "runtime"
+)
+
+// Cgroup is only implemented on Linux.
+type Cgroup struct {
+    Path string
+}
+
+func NewCgroup(name string, l Limits) (*Cgroup, error) {
+    return nil, fmt.Errorf("cgroups are not supported on %s", runtime.GOOS)
+}
+
+func (c *Cgroup) Attach(cmd *exec.Cmd) {}
+
+func (c *Cgroup) Usage() Usage { return Usage{Source: "cgroup"} }
+
+func (c *Cgroup) Remove() error { return nil }
@@ -0,0 +1,162 @@
+//go:build linux
+
+package sandbox
+
+import (
+    "encoding/binary"
+    "fmt"
+    "os"
+    "path/filepath"
+    "syscall"
+    "unsafe"
+)
+
+// Landlock syscall numbers are shared by all architectures.
+const (
+    sysLandlockCreateRuleset = 444
+    sysLandlockAddRule       = 445
+    sysLandlockRestrictSelf  = 446
+
+    landlockCreateRulesetVersion = 1 << 0
+    landlockRulePathBeneath      = 1
+
+    // Not exported by package syscall.
+    oPath           = 0x200000
+    prSetNoNewPrivs = 38
+)
+
+// Filesystem access rights (include/uapi/linux/landlock.h).
+const (
+    accessExecute    = 1 << 0
+    accessWriteFile  = 1 << 1
+    accessReadFile   = 1 << 2
+    accessReadDir    = 1 << 3
+    accessRemoveDir  = 1 << 4
+    accessRemoveFile = 1 << 5
+    accessMakeChar   = 1 << 6
+    accessMakeDir    = 1 << 7
+    accessMakeReg    = 1 << 8
+    accessMakeSock   = 1 << 9
+    accessMakeFifo   = 1 << 10
+    accessMakeBlock  = 1 << 11
+    accessMakeSym    = 1 << 12
+    accessRefer      = 1 << 13 // ABI 2
+    accessTruncate   = 1 << 14 // ABI 3
+
+    accessRead  = accessExecute | accessReadFile | accessReadDir
+    accessWrite = accessWriteFile | accessRemoveDir | accessRemoveFile | accessMakeChar |
+        accessMakeDir | accessMakeReg | accessMakeSock | accessMakeFifo | accessMakeBlock | accessMakeSym
+)
+
+// systemReadPaths stay readable whenever reads are restricted, so the wrapped
+// app can still load its interpreter and shared libraries.
+var systemReadPaths = []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc", "/opt", "/proc", "/sys", "/nix"}
+
+// systemDevices stay readable whenever reads are restricted: almost every
+// program opens /dev/null, /dev/urandom or /dev/tty. Only these nodes are
+// allowed, not disks and other devices under /dev.
+var systemDevices = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom", "/dev/tty", "/dev/ptmx", "/dev/pts", "/dev/shm"}
+
+// systemWritePaths stay writable whenever writes are restricted (/dev/null, ttys).
+var systemWritePaths = []string{"/dev"}
+
+// landlockABI returns the kernel's Landlock ABI version, or an error when
+// Landlock is unavailable (not built in, or disabled at boot).
+func landlockABI() (int, error) {
+    v, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+    if errno != 0 {
+        return 0, errno
+    }
+    return int(v), nil
+}
+
+func checkLandlock() error {
+    if _, err := landlockABI(); err != nil {
+        return fmt.Errorf("restricted profile: manifest declares filesystem policies but the kernel does not support Landlock (%v); refusing to run unrestricted (use --profile=permissive)", err)
+    }
+    return nil
+}
+
+// applyLandlock restricts the current process (and its future children) to
+// the filesystem allow-lists in spec. It must run right before exec.
+func applyLandlock(spec Spec, exe string) error {
+    abi, err := landlockABI()
+    if err != nil { return checkLandlock() }
+
+    var handled uint64
+    readAll := uint64(accessRead)
+    writeAll := uint64(accessWrite)
+    if abi >= 2 { writeAll |= accessRefer }
+    if abi >= 3 { writeAll |= accessTruncate }
+    if len(spec.FSRead) > 0 { handled |= readAll }
+    if len(spec.FSWrite) > 0 { handled |= writeAll }
+    if handled == 0 { return nil }
+
+    attr := struct{ handledAccessFS uint64 }{handled}
+    fd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+    if errno != 0 {
+        return fmt.Errorf("landlock: create ruleset: %w", errno)
+    }
+    ruleset := int(fd)
+    defer syscall.Close(ruleset)
+
+    // Every allowed path gets only the rights this ruleset handles.
+    rules := map[string]uint64{}
+    add := func(paths []string, access uint64) {
+        for _, p := range paths { rules[p] |= access & handled }
+    }
+    add(spec.FSRead, readAll)
+    add(spec.FSWrite, readAll|writeAll)
+    if len(spec.FSRead) > 0 {
+        add(systemReadPaths, readAll)
+        add(systemDevices, accessReadFile|accessReadDir)
+        add([]string{filepath.Dir(exe)}, readAll)
+    }
+    if len(spec.FSWrite) > 0 {
+        add(systemWritePaths, accessWriteFile|accessReadFile|accessReadDir)
+    }
+    for path, access := range rules {
+        if access == 0 { continue }
+        if err := addPathRule(ruleset, path, access); err != nil {
+            if os.IsNotExist(err) { continue }
+            return fmt.Errorf("landlock: allow %s: %w", path, err)
+        }
+    }
+
+    if err := prctlNoNewPrivs(); err != nil {
+        return fmt.Errorf("landlock: set no_new_privs: %w", err)
+    }
+    if _, _, errno := syscall.Syscall(sysLandlockRestrictSelf, uintptr(ruleset), 0, 0); errno != 0 {
+        return fmt.Errorf("landlock: restrict self: %w", errno)
+    }
+    return nil
+}
+
+func addPathRule(ruleset int, path string, access uint64) error {
+    fd, err := syscall.Open(path, oPath|syscall.O_CLOEXEC, 0)
+    if err != nil {
+        return &os.PathError{Op: "open", Path: path, Err: err}
+    }
+    defer syscall.Close(fd)
+    fi, err := os.Stat(path)
+    if err != nil { return err }
+    if !fi.IsDir() {
+        // Directory-only rights are rejected on files.
+        access &= accessExecute | accessWriteFile | accessReadFile | accessTruncate
+    }
+    // struct landlock_path_beneath_attr is packed: u64 allowed_access, s32 parent_fd.
+    var attr [12]byte
+    binary.NativeEndian.PutUint64(attr[0:8], access)
+    binary.NativeEndian.PutUint32(attr[8:12], uint32(int32(fd)))
+    if _, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(ruleset), landlockRulePathBeneath, uintptr(unsafe.Pointer(&attr[0])), 0, 0, 0); errno != 0 {
+        return errno
+    }
+    return nil
+}
+
+func prctlNoNewPrivs() error {
+    if _, _, errno := syscall.Syscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+        return errno
+    }
+    return nil
+}
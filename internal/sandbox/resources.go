@@ -0,0 +1,53 @@
+package sandbox
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Limits cap the resources of a wrapped app and all its descendants.
+// Zero fields are unlimited.
+type Limits struct {
+    MemoryMax int64   `json:"memory_max,omitempty"` // bytes
+    CPUMax    float64 `json:"cpu_max,omitempty"`    // CPUs' worth of time per period, e.g. 1.5
+    PidsMax   int     `json:"pids_max,omitempty"`
+    IOWeight  int     `json:"io_weight,omitempty"` // 1-10000, relative to siblings (default 100)
+}
+
+// Empty reports whether l limits nothing.
+func (l Limits) Empty() bool {
+    return l.MemoryMax == 0 && l.CPUMax == 0 && l.PidsMax == 0 && l.IOWeight == 0
+}
+
+// Describe lists the active limits, for logs.
+func (l Limits) Describe() []string {
+    var out []string
+    if l.MemoryMax > 0 { out = append(out, "memory="+FormatBytes(l.MemoryMax)) }
+    if l.CPUMax > 0 { out = append(out, "cpu="+strconv.FormatFloat(l.CPUMax, 'f', -1, 64)) }
+    if l.PidsMax > 0 { out = append(out, fmt.Sprintf("pids=%d", l.PidsMax)) }
+    if l.IOWeight > 0 { out = append(out, fmt.Sprintf("io_weight=%d", l.IOWeight)) }
+    return out
+}
+
+// Usage is what a wrapped app consumed, as far as Source could tell.
+type Usage struct {
+    PeakMemory int64         // bytes; for rusage, the largest single process
+    CPU        time.Duration // user + system time
+    OOMKills   int           // processes killed for exceeding MemoryMax (cgroup only)
+    Source     string        // "cgroup" or "rusage"
+}
+
+// FormatBytes renders n with a binary unit, e.g. 1.5GiB.
+func FormatBytes(n int64) string {
+    const unit = 1024
+    if n < unit { return fmt.Sprintf("%dB", n) }
+    div, exp := int64(unit), 0
+    for m := n / unit; m >= unit && exp < 4; m /= unit {
+        div *= unit
+        exp++
+    }
+    v := strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/float64(div)), ".0")
+    return v + string("KMGTP"[exp]) + "iB"
+}
@@ -0,0 +1,14 @@
+package sandbox
+
+import (
+    "os"
+
+    "golang.org/x/sys/unix"
+)
+
+// userProcesses counts the processes owned by our real uid.
+func userProcesses() int {
+    procs, err := unix.SysctlKinfoProcSlice("kern.proc.ruid", os.Getuid())
+    if err != nil { return 0 }
+    return len(procs)
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package sandbox
+
+// RlimitsSupported reports whether Spec.Rlimits can be applied here.
+const RlimitsSupported = false
+
+func applyRlimits(l Limits) error {
+    return nil
+}
@@ -0,0 +1,29 @@
+//go:build linux || darwin
+
+package sandbox
+
+import (
+    "fmt"
+
+    "golang.org/x/sys/unix"
+)
+
+// RlimitsSupported reports whether Spec.Rlimits can be applied here.
+const RlimitsSupported = true
+
+// applyRlimits is the fallback without cgroups, run in the sandbox init. It
+// caps each process's data segment, and the number of new processes via
+// RLIMIT_NPROC, which counts all of the user's processes (and does not bind
+// root). CPU and IO limits have no rlimit equivalent.
+func applyRlimits(l Limits) error {
+    if l.MemoryMax > 0 {
+        lim := unix.Rlimit{Cur: uint64(l.MemoryMax), Max: uint64(l.MemoryMax)}
+        if err := unix.Setrlimit(unix.RLIMIT_DATA, &lim); err != nil { return fmt.Errorf("sandbox: RLIMIT_DATA: %w", err) }
+    }
+    if l.PidsMax > 0 {
+        n := uint64(userProcesses() + l.PidsMax)
+        lim := unix.Rlimit{Cur: n, Max: n}
+        if err := unix.Setrlimit(unix.RLIMIT_NPROC, &lim); err != nil { return fmt.Errorf("sandbox: RLIMIT_NPROC: %w", err) }
+    }
+    return nil
+}
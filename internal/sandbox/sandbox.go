@@ -0,0 +1,88 @@
+package sandbox
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "syscall"
+)
+
+// InitArg is the hidden first argument that makes the heimdal binary act as
+// the in-sandbox init: apply the Spec, then exec the real command.
+const InitArg = "__sandbox-exec"
+
+// Spec describes the restrictions applied to a wrapped app.
+type Spec struct {
+    // IsolateNetwork runs the app in a new network namespace with only loopback.
+    IsolateNetwork bool `json:"isolate_network,omitempty"`
+    // FSRead and FSWrite are absolute paths the app may read / write beneath.
+    // A non-empty list restricts that kind of access to the listed paths.
+    FSRead  []string `json:"fs_read,omitempty"`
+    FSWrite []string `json:"fs_write,omitempty"`
+    // Rlimits are applied with setrlimit when no cgroup can hold the app.
+    Rlimits Limits `json:"rlimits,omitempty"`
+}
+
+// Empty reports whether the spec restricts nothing.
+func (s Spec) Empty() bool {
+    return !s.IsolateNetwork && len(s.FSRead) == 0 && len(s.FSWrite) == 0 && s.Rlimits.Empty()
+}
+
+// Describe lists the active restrictions, for logs.
+func (s Spec) Describe() []string {
+    var out []string
+    if s.IsolateNetwork { out = append(out, "network:loopback") }
+    if len(s.FSRead) > 0 { out = append(out, "fs-read:"+strings.Join(s.FSRead, ":")) }
+    if len(s.FSWrite) > 0 { out = append(out, "fs-write:"+strings.Join(s.FSWrite, ":")) }
+    if !s.Rlimits.Empty() { out = append(out, "rlimit:"+strings.Join(s.Rlimits.Describe(), ",")) }
+    return out
+}
+
+// ResolvePaths expands env vars in manifest paths and makes them absolute
+// relative to workdir.
+func ResolvePaths(workdir string, paths []string) []string {
+    out := make([]string, 0, len(paths))
+    for _, p := range paths {
+        p = os.ExpandEnv(p)
+        if p == "" { continue }
+        if !filepath.IsAbs(p) { p = filepath.Join(workdir, p) }
+        out = append(out, filepath.Clean(p))
+    }
+    return out
+}
+
+// Command builds the exec.Cmd for name/args under spec. With an empty spec it is
+// a plain exec.Command; otherwise heimdal re-executes itself as the sandbox init.
+func Command(spec Spec, name string, args ...string) (*exec.Cmd, error) {
+    if spec.Empty() {
+        return exec.Command(name, args...), nil
+    }
+    path, err := exec.LookPath(name)
+    if err != nil { return nil, err }
+    self, err := os.Executable()
+    if err != nil { return nil, fmt.Errorf("sandbox: locate heimdal binary: %w", err) }
+    b, err := json.Marshal(spec)
+    if err != nil { return nil, err }
+    initArgs := append([]string{InitArg, string(b), path, name}, args...)
+    cmd := exec.Command(self, initArgs...)
+    if err := configure(cmd, spec); err != nil { return nil, err }
+    return cmd, nil
+}
+
+// Init runs inside the sandboxed child. args are everything after InitArg:
+// the JSON spec, the resolved binary path, then argv. It only returns on error.
+func Init(args []string) error {
+    if len(args) < 3 {
+        return errors.New("sandbox: malformed init arguments")
+    }
+    var spec Spec
+    if err := json.Unmarshal([]byte(args[0]), &spec); err != nil {
+        return fmt.Errorf("sandbox: decode spec: %w", err)
+    }
+    if err := apply(spec, args[1]); err != nil { return err }
+    return syscall.Exec(args[1], args[2:], os.Environ())
+}
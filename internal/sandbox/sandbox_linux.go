@@ -0,0 +1,85 @@
+//go:build linux
+
+package sandbox
+
+import (
+    "fmt"
+    "os"
+    "os/exec"
+    "syscall"
+    "unsafe"
+
+    "golang.org/x/sys/unix"
+)
+
+// configure sets up the namespaces the child is cloned into.
+func configure(cmd *exec.Cmd, spec Spec) error {
+    if len(spec.FSRead) > 0 || len(spec.FSWrite) > 0 {
+        // Fail in the parent, before anything runs, if Landlock is missing.
+        if err := checkLandlock(); err != nil { return err }
+    }
+    attr := &syscall.SysProcAttr{}
+    if spec.IsolateNetwork {
+        // An unprivileged user namespace lets us create the network namespace
+        // without root. Map our own uid/gid so file ownership looks unchanged.
+        // A non-zero uid loses its namespace capabilities at exec, so the init
+        // keeps CAP_NET_ADMIN as an ambient capability to bring up loopback,
+        // and clears it before exec'ing the app.
+        attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+        attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+        attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+        attr.GidMappingsEnableSetgroups = false
+        attr.AmbientCaps = []uintptr{unix.CAP_NET_ADMIN}
+    }
+    cmd.SysProcAttr = attr
+    return nil
+}
+
+// apply runs in the child, inside the new namespaces, before exec'ing exe.
+func apply(spec Spec, exe string) error {
+    if err := applyRlimits(spec.Rlimits); err != nil { return err }
+    if spec.IsolateNetwork {
+        if err := loopbackUp(); err != nil {
+            return fmt.Errorf("sandbox: bring up loopback: %w", err)
+        }
+        if err := dropCaps(); err != nil {
+            return fmt.Errorf("sandbox: drop capabilities: %w", err)
+        }
+    }
+    // Landlock last: it also limits what the steps above could touch.
+    return applyLandlock(spec, exe)
+}
+
+type ifreqFlags struct {
+    Name  [syscall.IFNAMSIZ]byte
+    Flags uint16
+    _     [22]byte
+}
+
+// loopbackUp sets IFF_UP on "lo"; a fresh network namespace starts with it down.
+func loopbackUp() error {
+    fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+    if err != nil { return err }
+    defer syscall.Close(fd)
+    var req ifreqFlags
+    copy(req.Name[:], "lo")
+    if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&req))); errno != 0 {
+        return errno
+    }
+    req.Flags |= syscall.IFF_UP
+    if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&req))); errno != 0 {
+        return errno
+    }
+    return nil
+}
+
+// dropCaps clears the ambient and inheritable capabilities the init was
+// given, so none reach the app.
+func dropCaps() error {
+    if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil { return err }
+    hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+    var data [2]unix.CapUserData
+    if err := unix.Capget(&hdr, &data[0]); err != nil { return err }
+    data[0].Inheritable, data[1].Inheritable = 0, 0
+    return unix.Capset(&hdr, &data[0])
+}
@@ -0,0 +1,108 @@
+//go:build linux
+
+package sandbox
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "syscall"
+    "testing"
+)
+
+// The test binary doubles as the sandbox init, as heimdal does.
+func TestMain(m *testing.M) {
+    if len(os.Args) > 1 && os.Args[1] == InitArg {
+        if err := Init(os.Args[2:]); err != nil {
+            fmt.Fprintln(os.Stderr, "error:", err)
+            os.Exit(126)
+        }
+    }
+    os.Exit(m.Run())
+}
+
+// nobody is the uid the non-root test drops to when run as root.
+const nobody = 65534
+
+func TestIsolateNetworkNonRoot(t *testing.T) {
+    if os.Getuid() == 0 {
+        rerunAsNobody(t)
+        return
+    }
+    cmd, err := Command(Spec{IsolateNetwork: true}, "sh", "-c", "cat /proc/net/dev; grep CapEff /proc/self/status")
+    if err != nil { t.Fatal(err) }
+    out, err := cmd.CombinedOutput()
+    var ee *exec.ExitError
+    if err != nil && !errors.As(err, &ee) { t.Skipf("no user namespaces here: %v", err) }
+    if err != nil { t.Fatalf("sandboxed run failed: %v\n%s", err, out) }
+    if !strings.Contains(string(out), "lo:") { t.Errorf("no loopback in the namespace:\n%s", out) }
+    for _, line := range strings.Split(string(out), "\n") {
+        if strings.HasPrefix(line, "CapEff:") && strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")) != "0000000000000000" {
+            t.Errorf("app kept capabilities: %s", line)
+        }
+    }
+}
+
+// rerunAsNobody runs the calling test again from a copy of the test binary
+// that nobody can execute, as uid and gid nobody.
+func rerunAsNobody(t *testing.T) {
+    dir, err := os.MkdirTemp("", "heimdal-sandbox-test-")
+    if err != nil { t.Fatal(err) }
+    defer os.RemoveAll(dir)
+    if err := os.Chmod(dir, 0o755); err != nil { t.Fatal(err) }
+    bin := filepath.Join(dir, "sandbox.test")
+    if err := copyFile(os.Args[0], bin); err != nil { t.Fatal(err) }
+    cmd := exec.Command(bin, "-test.run=^"+t.Name()+"$", "-test.v")
+    cmd.Dir = dir
+    cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: nobody, Gid: nobody}}
+    out, err := cmd.CombinedOutput()
+    t.Logf("as uid %d:\n%s", nobody, out)
+    if err != nil { t.Fatal(err) }
+}
+
+func copyFile(src, dst string) error {
+    in, err := os.Open(src)
+    if err != nil { return err }
+    defer in.Close()
+    out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+    if err != nil { return err }
+    if _, err := io.Copy(out, in); err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
+
+// TestCgroupProbe checks that probe tells a usable group from one the kernel
+// will not start processes in, which NewCgroup turns into the rlimit fallback.
+func TestCgroupProbe(t *testing.T) {
+    notGroup, err := os.Open(t.TempDir())
+    if err != nil { t.Fatal(err) }
+    defer notGroup.Close()
+    if err := (&Cgroup{dir: notGroup}).probe(); err == nil { t.Error("probe into a plain directory: want error") }
+
+    mount, err := cgroup2Mount()
+    if err != nil { t.Skipf("no cgroup v2: %v", err) }
+    rel, err := ownCgroup()
+    if err != nil { t.Skip(err) }
+    own, err := os.Open(filepath.Join(mount, rel))
+    if err != nil { t.Skip(err) }
+    defer own.Close()
+    if err := (&Cgroup{dir: own}).probe(); err != nil { t.Errorf("probe into our own cgroup: %v", err) }
+}
+
+// TestLandlockReadOnlyDevices checks that restricting reads alone still lets
+// the app use the standard device nodes.
+func TestLandlockReadOnlyDevices(t *testing.T) {
+    if _, err := landlockABI(); err != nil { t.Skipf("no Landlock: %v", err) }
+    outside := filepath.Join(t.TempDir(), "secret")
+    if err := os.WriteFile(outside, []byte("x"), 0o644); err != nil { t.Fatal(err) }
+    cmd, err := Command(Spec{FSRead: []string{t.TempDir()}}, "sh", "-c",
+        "echo x >/dev/null && head -c 4 /dev/urandom >/dev/null && cat /dev/null && ! cat "+outside+" 2>/dev/null")
+    if err != nil { t.Fatal(err) }
+    if out, err := cmd.CombinedOutput(); err != nil { t.Fatalf("sandboxed run failed: %v\n%s", err, out) }
+}
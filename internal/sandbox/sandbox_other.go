@@ -0,0 +1,21 @@
+//go:build !linux
+
+package sandbox
+
+import (
+    "fmt"
+    "os/exec"
+    "runtime"
+)
+
+func configure(cmd *exec.Cmd, spec Spec) error {
+    if !spec.IsolateNetwork && len(spec.FSRead) == 0 && len(spec.FSWrite) == 0 {
+        if RlimitsSupported { return nil }
+        return fmt.Errorf("resources: limits are not supported on %s", runtime.GOOS)
+    }
+    return fmt.Errorf("restricted profile: network and filesystem isolation are not supported on %s (use --profile=permissive)", runtime.GOOS)
+}
+
+func apply(spec Spec, exe string) error {
+    return applyRlimits(spec.Rlimits)
+}
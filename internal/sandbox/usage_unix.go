@@ -0,0 +1,22 @@
+//go:build !windows
+
+package sandbox
+
+import (
+    "os"
+    "runtime"
+    "syscall"
+)
+
+// ProcessUsage reports what the exited process ps and the descendants it
+// waited for used, from getrusage.
+func ProcessUsage(ps *os.ProcessState) Usage {
+    u := Usage{Source: "rusage"}
+    if ps == nil { return u }
+    u.CPU = ps.UserTime() + ps.SystemTime()
+    if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+        u.PeakMemory = int64(ru.Maxrss)
+        if runtime.GOOS != "darwin" { u.PeakMemory *= 1024 } // kilobytes everywhere else
+    }
+    return u
+}
@@ -0,0 +1,11 @@
+package sandbox
+
+import "os"
+
+// ProcessUsage reports the CPU time of the exited process ps.
+func ProcessUsage(ps *os.ProcessState) Usage {
+    u := Usage{Source: "rusage"}
+    if ps == nil { return u }
+    u.CPU = ps.UserTime() + ps.SystemTime()
+    return u
+}
@@ -0,0 +1,187 @@
+package secret
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+
+    "golang.org/x/crypto/scrypt"
+    "golang.org/x/term"
+)
+
+// PassphraseEnv, when set, supplies the file store's passphrase without a
+// prompt, e.g. for scripts.
+const PassphraseEnv = "HEIMDAL_SECRET_PASSPHRASE"
+
+// UnlockEnv lists the env vars that unlock a store. Wrapped apps never
+// inherit them, whatever the profile or manifest says.
+var UnlockEnv = []string{PassphraseEnv}
+
+// scrypt parameters for new stores (the interactive-login recommendation).
+const (
+    scryptN = 1 << 15
+    scryptR = 8
+    scryptP = 1
+)
+
+// checkValue is encrypted into every store so a wrong passphrase is
+// reported as such, even before any secret has been set.
+const checkValue = "heimdal"
+
+// FileStore encrypts each value with AES-256-GCM under a key derived from a
+// passphrase with scrypt. Names are stored in the clear.
+type FileStore struct {
+    Path string
+    // Passphrase obtains the passphrase; confirm asks twice, for new stores.
+    Passphrase func(confirm bool) ([]byte, error)
+    key        []byte
+}
+
+type fileData struct {
+    Version int               `json:"version"`
+    KDF     kdfParams         `json:"kdf"`
+    Check   []byte            `json:"check"`
+    Secrets map[string][]byte `json:"secrets"` // name -> nonce || ciphertext
+}
+
+type kdfParams struct {
+    Name string `json:"name"`
+    Salt []byte `json:"salt"`
+    N    int    `json:"n"`
+    R    int    `json:"r"`
+    P    int    `json:"p"`
+}
+
+func (s *FileStore) load() (*fileData, error) {
+    b, err := os.ReadFile(s.Path)
+    if errors.Is(err, os.ErrNotExist) { return nil, nil }
+    if err != nil { return nil, err }
+    var d fileData
+    if err := json.Unmarshal(b, &d); err != nil { return nil, fmt.Errorf("%s: %w", s.Path, err) }
+    if d.Version != 1 || d.KDF.Name != "scrypt" {
+        return nil, fmt.Errorf("%s: unsupported store version %d (%s)", s.Path, d.Version, d.KDF.Name)
+    }
+    if d.Secrets == nil { d.Secrets = map[string][]byte{} }
+    return &d, nil
+}
+
+func (s *FileStore) save(d *fileData) error {
+    b, err := json.MarshalIndent(d, "", "  ")
+    if err != nil { return err }
+    if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil { return err }
+    tmp := s.Path + ".tmp"
+    if err := os.WriteFile(tmp, b, 0o600); err != nil { return err }
+    return os.Rename(tmp, s.Path)
+}
+
+// unlock derives the key for d, creating d's KDF parameters when d is new.
+func (s *FileStore) unlock(d *fileData) (cipher.AEAD, error) {
+    if s.key == nil {
+        pass, err := s.Passphrase(d.Check == nil)
+        if err != nil { return nil, err }
+        if d.KDF.Salt == nil {
+            d.KDF = kdfParams{Name: "scrypt", Salt: make([]byte, 16), N: scryptN, R: scryptR, P: scryptP}
+            if _, err := rand.Read(d.KDF.Salt); err != nil { return nil, err }
+        }
+        s.key, err = scrypt.Key(pass, d.KDF.Salt, d.KDF.N, d.KDF.R, d.KDF.P, 32)
+        if err != nil { return nil, err }
+    }
+    block, err := aes.NewCipher(s.key)
+    if err != nil { return nil, err }
+    aead, err := cipher.NewGCM(block)
+    if err != nil { return nil, err }
+    if d.Check == nil {
+        d.Check, err = seal(aead, "", checkValue)
+        if err != nil { return nil, err }
+    } else if v, err := open(aead, "", d.Check); err != nil || v != checkValue {
+        s.key = nil
+        return nil, errors.New("wrong passphrase")
+    }
+    return aead, nil
+}
+
+// seal encrypts value, binding it to name so entries cannot be swapped.
+func seal(aead cipher.AEAD, name, value string) ([]byte, error) {
+    nonce := make([]byte, aead.NonceSize())
+    if _, err := io.ReadFull(rand.Reader, nonce); err != nil { return nil, err }
+    return aead.Seal(nonce, nonce, []byte(value), []byte(name)), nil
+}
+
+func open(aead cipher.AEAD, name string, box []byte) (string, error) {
+    if len(box) < aead.NonceSize() { return "", errors.New("corrupt secret") }
+    n := aead.NonceSize()
+    plain, err := aead.Open(nil, box[:n], box[n:], []byte(name))
+    if err != nil { return "", err }
+    return string(plain), nil
+}
+
+func (s *FileStore) Get(name string) (string, error) {
+    d, err := s.load()
+    if err != nil { return "", err }
+    if d == nil || d.Secrets[name] == nil { return "", fmt.Errorf("%w: %s", ErrNotFound, name) }
+    aead, err := s.unlock(d)
+    if err != nil { return "", err }
+    v, err := open(aead, name, d.Secrets[name])
+    if err != nil { return "", fmt.Errorf("secret %s: %w", name, err) }
+    return v, nil
+}
+
+func (s *FileStore) Set(name, value string) error {
+    if err := CheckName(name); err != nil { return err }
+    d, err := s.load()
+    if err != nil { return err }
+    if d == nil { d = &fileData{Version: 1, Secrets: map[string][]byte{}} }
+    aead, err := s.unlock(d)
+    if err != nil { return err }
+    d.Secrets[name], err = seal(aead, name, value)
+    if err != nil { return err }
+    return s.save(d)
+}
+
+func (s *FileStore) Remove(name string) error {
+    d, err := s.load()
+    if err != nil { return err }
+    if d == nil || d.Secrets[name] == nil { return fmt.Errorf("%w: %s", ErrNotFound, name) }
+    delete(d.Secrets, name)
+    return s.save(d)
+}
+
+func (s *FileStore) List() ([]string, error) {
+    d, err := s.load()
+    if err != nil || d == nil { return nil, err }
+    names := make([]string, 0, len(d.Secrets))
+    for n := range d.Secrets { names = append(names, n) }
+    sort.Strings(names)
+    return names, nil
+}
+
+// Passphrase reads the passphrase from $HEIMDAL_SECRET_PASSPHRASE or, without
+// echo, from the terminal.
+func Passphrase(confirm bool) ([]byte, error) {
+    if p, ok := os.LookupEnv(PassphraseEnv); ok { return []byte(p), nil }
+    tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+    if err != nil { return nil, fmt.Errorf("secret store is locked: set %s or run from a terminal", PassphraseEnv) }
+    defer tty.Close()
+    read := func(prompt string) ([]byte, error) {
+        fmt.Fprint(tty, prompt)
+        p, err := term.ReadPassword(int(tty.Fd()))
+        fmt.Fprintln(tty)
+        return p, err
+    }
+    p, err := read("heimdal secrets passphrase: ")
+    if err != nil { return nil, err }
+    if len(p) == 0 { return nil, errors.New("empty passphrase") }
+    if confirm {
+        again, err := read("repeat passphrase: ")
+        if err != nil { return nil, err }
+        if string(again) != string(p) { return nil, errors.New("passphrases do not match") }
+    }
+    return p, nil
+}
@@ -0,0 +1,110 @@
+package secret
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "runtime"
+    "sort"
+    "strings"
+)
+
+// keyringService is the service/attribute under which secrets are filed.
+const keyringService = "heimdal"
+
+// Keyring stores secrets in the OS keyring through its CLI: secret-tool
+// (libsecret) on Linux, security on macOS. Keyrings cannot be listed
+// portably, so names are also kept in ~/.heimdall/keyring.json.
+type Keyring struct {
+    tool  string
+    index string
+}
+
+func newKeyring() (*Keyring, error) {
+    tool := "secret-tool"
+    if runtime.GOOS == "darwin" { tool = "security" }
+    if _, err := exec.LookPath(tool); err != nil {
+        return nil, fmt.Errorf("keyring secret store needs %s: %w", tool, err)
+    }
+    home, err := os.UserHomeDir()
+    if err != nil { return nil, err }
+    return &Keyring{tool: tool, index: filepath.Join(home, ".heimdall", "keyring.json")}, nil
+}
+
+func (k *Keyring) run(stdin string, args ...string) (string, error) {
+    cmd := exec.Command(k.tool, args...)
+    cmd.Stdin = strings.NewReader(stdin)
+    var stderr bytes.Buffer
+    cmd.Stderr = &stderr
+    out, err := cmd.Output()
+    if err != nil {
+        return "", fmt.Errorf("%s %s: %w: %s", k.tool, args[0], err, strings.TrimSpace(stderr.String()))
+    }
+    return string(out), nil
+}
+
+func (k *Keyring) Get(name string) (string, error) {
+    var out string
+    var err error
+    if k.tool == "security" {
+        out, err = k.run("", "find-generic-password", "-s", keyringService, "-a", name, "-w")
+        out = strings.TrimSuffix(out, "\n")
+    } else {
+        // lookup prints nothing and exits 1 for a missing item.
+        out, err = k.run("", "lookup", "service", keyringService, "name", name)
+    }
+    if err != nil || out == "" { return "", fmt.Errorf("%w: %s", ErrNotFound, name) }
+    return out, nil
+}
+
+func (k *Keyring) Set(name, value string) error {
+    if err := CheckName(name); err != nil { return err }
+    var err error
+    if k.tool == "security" {
+        // security only takes the password as an argument.
+        _, err = k.run("", "add-generic-password", "-U", "-s", keyringService, "-a", name, "-w", value)
+    } else {
+        _, err = k.run(value, "store", "--label=heimdal: "+name, "service", keyringService, "name", name)
+    }
+    if err != nil { return err }
+    return k.updateIndex(name, true)
+}
+
+func (k *Keyring) Remove(name string) error {
+    var err error
+    if k.tool == "security" {
+        _, err = k.run("", "delete-generic-password", "-s", keyringService, "-a", name)
+    } else {
+        _, err = k.run("", "clear", "service", keyringService, "name", name)
+    }
+    if err != nil { return fmt.Errorf("%w: %s", ErrNotFound, name) }
+    return k.updateIndex(name, false)
+}
+
+func (k *Keyring) List() ([]string, error) {
+    b, err := os.ReadFile(k.index)
+    if errors.Is(err, os.ErrNotExist) { return nil, nil }
+    if err != nil { return nil, err }
+    var names []string
+    if err := json.Unmarshal(b, &names); err != nil { return nil, fmt.Errorf("%s: %w", k.index, err) }
+    return names, nil
+}
+
+func (k *Keyring) updateIndex(name string, add bool) error {
+    names, err := k.List()
+    if err != nil { return err }
+    set := map[string]bool{}
+    for _, n := range names { set[n] = true }
+    if add { set[name] = true } else { delete(set, name) }
+    names = names[:0]
+    for n := range set { names = append(names, n) }
+    sort.Strings(names)
+    b, err := json.Marshal(names)
+    if err != nil { return err }
+    if err := os.MkdirAll(filepath.Dir(k.index), 0o700); err != nil { return err }
+    return os.WriteFile(k.index, b, 0o600)
+}
@@ -0,0 +1,65 @@
+package secret
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// RefPrefix marks a manifest env value to be read from the store, e.g.
+// GEMINI_API_KEY: secret://gemini.
+const RefPrefix = "secret://"
+
+// ErrNotFound is returned for unknown secret names.
+var ErrNotFound = errors.New("secret not found")
+
+// Store keeps named secrets.
+type Store interface {
+    Get(name string) (string, error)
+    Set(name, value string) error
+    Remove(name string) error
+    // List returns the stored names, sorted. It never needs the passphrase.
+    List() ([]string, error)
+}
+
+var nameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// CheckName rejects names that would be awkward in refs and keyrings.
+func CheckName(name string) error {
+    if !nameRe.MatchString(name) {
+        return fmt.Errorf("invalid secret name %q (letters, digits, '_', '.', '-')", name)
+    }
+    return nil
+}
+
+// ParseRef returns the secret name if v is a secret:// reference.
+func ParseRef(v string) (string, bool) {
+    if !strings.HasPrefix(v, RefPrefix) { return "", false }
+    return strings.TrimPrefix(v, RefPrefix), true
+}
+
+// Open returns the store for backend: "file" (passphrase-encrypted
+// ~/.heimdall/secrets.json) or "keyring" (the OS keyring via secret-tool or
+// security).
+func Open(backend string) (Store, error) {
+    switch backend {
+    case "", "file":
+        path, err := FilePath()
+        if err != nil { return nil, err }
+        return &FileStore{Path: path, Passphrase: Passphrase}, nil
+    case "keyring":
+        return newKeyring()
+    default:
+        return nil, fmt.Errorf("unknown secret store %q (want file|keyring)", backend)
+    }
+}
+
+// FilePath returns ~/.heimdall/secrets.json.
+func FilePath() (string, error) {
+    home, err := os.UserHomeDir()
+    if err != nil { return "", err }
+    return filepath.Join(home, ".heimdall", "secrets.json"), nil
+}
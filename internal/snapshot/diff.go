@@ -0,0 +1,171 @@
+package snapshot
+
+import (
+    "fmt"
+    "strings"
+)
+
+// maxEdits bounds the diff search; files that differ more than this are
+// shown as entirely replaced.
+const maxEdits = 4000
+
+// context is the number of unchanged lines around each hunk.
+const context = 3
+
+type edit struct {
+    op   byte // ' ', '-' or '+'
+    line string
+}
+
+// Unified returns a unified diff from a to b with the given file labels, or
+// "" when they are equal.
+func Unified(aName, bName string, a, b []byte) string {
+    al, bl := splitLines(string(a)), splitLines(string(b))
+    edits := diffLines(al, bl)
+    var out strings.Builder
+    // Walk the edits, emitting hunks of changes with their context.
+    i := 0
+    aLine, bLine := 1, 1
+    for i < len(edits) {
+        if edits[i].op == ' ' {
+            i++
+            aLine++
+            bLine++
+            continue
+        }
+        // Start a hunk `context` lines before this change.
+        start := i
+        for n := 0; n < context && start > 0 && edits[start-1].op == ' '; n++ { start-- }
+        hunkA, hunkB := aLine-(i-start), bLine-(i-start)
+        // Extend it while the next change is within 2*context unchanged lines.
+        end := i
+        for j := i; j < len(edits); {
+            if edits[j].op != ' ' {
+                end = j + 1
+                j++
+                continue
+            }
+            run := 0
+            for j < len(edits) && edits[j].op == ' ' {
+                run++
+                j++
+            }
+            if j == len(edits) || run > 2*context { break }
+        }
+        stop := end
+        for n := 0; n < context && stop < len(edits) && edits[stop].op == ' '; n++ { stop++ }
+
+        if out.Len() == 0 { fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName) }
+        var body strings.Builder
+        countA, countB := 0, 0
+        for _, e := range edits[start:stop] {
+            body.WriteByte(e.op)
+            body.WriteString(e.line)
+            if !strings.HasSuffix(e.line, "\n") { body.WriteString("\n\\ No newline at end of file\n") }
+            if e.op != '+' { countA++ }
+            if e.op != '-' { countB++ }
+        }
+        fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(hunkA, countA), hunkRange(hunkB, countB))
+        out.WriteString(body.String())
+        for _, e := range edits[i:stop] {
+            if e.op != '+' { aLine++ }
+            if e.op != '-' { bLine++ }
+        }
+        i = stop
+    }
+    return out.String()
+}
+
+func hunkRange(start, count int) string {
+    if count == 0 { start-- } // an empty range names the line before it
+    if count == 1 { return fmt.Sprint(start) }
+    return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines splits s after each newline, keeping the newlines.
+func splitLines(s string) []string {
+    var lines []string
+    for s != "" {
+        i := strings.IndexByte(s, '\n')
+        if i < 0 {
+            lines = append(lines, s)
+            break
+        }
+        lines = append(lines, s[:i+1])
+        s = s[i+1:]
+    }
+    return lines
+}
+
+// diffLines computes a shortest edit script with Myers' algorithm.
+func diffLines(a, b []string) []edit {
+    n, m := len(a), len(b)
+    max := n + m
+    if max == 0 { return nil }
+    off := max
+    v := make([]int, 2*max+2)
+    var trace [][]int // v[-d..d] before step d
+    found := false
+    for d := 0; d <= max && d <= maxEdits && !found; d++ {
+        trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+        for k := -d; k <= d; k += 2 {
+            var x int
+            if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+                x = v[off+k+1]
+            } else {
+                x = v[off+k-1] + 1
+            }
+            y := x - k
+            for x < n && y < m && a[x] == b[y] {
+                x++
+                y++
+            }
+            v[off+k] = x
+            if x >= n && y >= m {
+                found = true
+                break
+            }
+        }
+    }
+    if !found {
+        var out []edit
+        for _, l := range a { out = append(out, edit{'-', l}) }
+        for _, l := range b { out = append(out, edit{'+', l}) }
+        return out
+    }
+
+    var rev []edit
+    x, y := n, m
+    for d := len(trace) - 1; d > 0; d-- {
+        prev := trace[d] // indexed by k+d
+        k := x - y
+        var pk int
+        if k == -d || (k != d && prev[k-1+d] < prev[k+1+d]) {
+            pk = k + 1
+        } else {
+            pk = k - 1
+        }
+        px := prev[pk+d]
+        py := px - pk
+        for x > px && y > py {
+            x--
+            y--
+            rev = append(rev, edit{' ', a[x]})
+        }
+        if x == px {
+            y--
+            rev = append(rev, edit{'+', b[y]})
+        } else {
+            x--
+            rev = append(rev, edit{'-', a[x]})
+        }
+    }
+    for x > 0 && y > 0 {
+        x--
+        y--
+        rev = append(rev, edit{' ', a[x]})
+    }
+    out := make([]edit, len(rev))
+    for i, e := range rev { out[len(rev)-1-i] = e }
+    return out
+}
@@ -0,0 +1,192 @@
+package snapshot
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sort"
+    "time"
+
+    "heimdal/internal/redact"
+)
+
+// Limits on what a snapshot keeps. Larger files are still tracked by
+// metadata and hash; their content just cannot be diffed later.
+const (
+    MaxHashSize = 64 << 20  // files above this are compared by size and mtime only
+    MaxBlobSize = 256 << 10 // text files up to this size are stored for diffs
+    MaxStored   = 64 << 20  // total content stored per session
+)
+
+// Entry is the state of one file.
+type Entry struct {
+    Size    int64       `json:"size"`
+    Mode    fs.FileMode `json:"mode"`
+    ModTime time.Time   `json:"mtime"`
+    Hash    string      `json:"sha256,omitempty"` // of the content, or a symlink's target
+    Stored  bool        `json:"stored,omitempty"` // content is in the Store
+    Binary  bool        `json:"binary,omitempty"`
+}
+
+// Snapshot is the state of every file under Root, keyed by slash path.
+type Snapshot struct {
+    Root  string           `json:"root"`
+    Taken time.Time        `json:"taken"`
+    Files map[string]Entry `json:"files"`
+}
+
+// Take records files (slash paths relative to root). Unchanged files (same
+// size, mode and mtime as in prev) reuse prev's hash instead of being read
+// again. Text content is copied into store, if set, for later diffs.
+func Take(root string, files []string, prev *Snapshot, store *Store) *Snapshot {
+    s := &Snapshot{Root: root, Taken: time.Now().UTC(), Files: map[string]Entry{}}
+    for _, rel := range files {
+        path := filepath.Join(root, filepath.FromSlash(rel))
+        fi, err := os.Lstat(path)
+        if err != nil { continue }
+        e := Entry{Size: fi.Size(), Mode: fi.Mode(), ModTime: fi.ModTime().UTC()}
+        if p, ok := prev.lookup(rel); ok && p.Hash != "" && p.Size == e.Size && p.Mode == e.Mode && p.ModTime.Equal(e.ModTime) {
+            s.Files[rel] = p
+            continue
+        }
+        switch {
+        case fi.Mode()&fs.ModeSymlink != 0:
+            if target, err := os.Readlink(path); err == nil { e.Hash = hash([]byte(target)) }
+        case fi.Mode().IsRegular() && fi.Size() <= MaxHashSize:
+            data, err := os.ReadFile(path)
+            if err != nil { break }
+            e.Hash = hash(data)
+            e.Binary = IsBinary(data)
+            if !e.Binary && len(data) <= MaxBlobSize { e.Stored = store.Put(e.Hash, data) }
+        }
+        s.Files[rel] = e
+    }
+    return s
+}
+
+func (s *Snapshot) lookup(rel string) (Entry, bool) {
+    if s == nil { return Entry{}, false }
+    e, ok := s.Files[rel]
+    return e, ok
+}
+
+func hash(data []byte) string {
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
+
+// IsBinary reports whether data looks binary: a NUL byte near the start.
+func IsBinary(data []byte) bool {
+    if len(data) > 8000 { data = data[:8000] }
+    return bytes.IndexByte(data, 0) >= 0
+}
+
+// Load reads a snapshot written by Save.
+func Load(path string) (*Snapshot, error) {
+    b, err := os.ReadFile(path)
+    if err != nil { return nil, err }
+    var s Snapshot
+    if err := json.Unmarshal(b, &s); err != nil { return nil, err }
+    if s.Files == nil { s.Files = map[string]Entry{} }
+    return &s, nil
+}
+
+// Save writes s as JSON.
+func Save(path string, s *Snapshot) error {
+    b, err := json.Marshal(s)
+    if err != nil { return err }
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { return err }
+    return os.WriteFile(path, b, 0o600)
+}
+
+// Change kinds.
+const (
+    Added    = "added"
+    Modified = "modified"
+    Deleted  = "deleted"
+)
+
+// Change is a file that differs between two snapshots. Old is nil for an
+// added file and New for a deleted one.
+type Change struct {
+    Path string `json:"path"`
+    Kind string `json:"kind"`
+    Old  *Entry `json:"old,omitempty"`
+    New  *Entry `json:"new,omitempty"`
+}
+
+// Compare lists the files that differ from a to b, by path.
+func Compare(a, b *Snapshot) []Change {
+    var out []Change
+    for rel, ne := range b.Files {
+        ne := ne
+        oe, ok := a.Files[rel]
+        switch {
+        case !ok:
+            out = append(out, Change{Path: rel, Kind: Added, New: &ne})
+        case changed(oe, ne):
+            oe := oe
+            out = append(out, Change{Path: rel, Kind: Modified, Old: &oe, New: &ne})
+        }
+    }
+    for rel, oe := range a.Files {
+        oe := oe
+        if _, ok := b.Files[rel]; !ok { out = append(out, Change{Path: rel, Kind: Deleted, Old: &oe}) }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+    return out
+}
+
+func changed(a, b Entry) bool {
+    if a.Mode != b.Mode { return true }
+    if a.Hash != "" && b.Hash != "" { return a.Hash != b.Hash }
+    return a.Size != b.Size || !a.ModTime.Equal(b.ModTime)
+}
+
+// Store keeps file contents by hash under Dir, up to MaxStored bytes in
+// total. Contents are redacted before they are written.
+type Store struct {
+    Dir      string
+    Redactor *redact.Redactor
+    used     int64
+}
+
+// NewStore opens the store at dir, counting what it already holds.
+func NewStore(dir string, red *redact.Redactor) *Store {
+    st := &Store{Dir: dir, Redactor: red}
+    _ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+        if err == nil && !d.IsDir() {
+            if fi, err := d.Info(); err == nil { st.used += fi.Size() }
+        }
+        return nil
+    })
+    return st
+}
+
+// Put stores data under its hash and reports whether it is now stored.
+func (st *Store) Put(h string, data []byte) bool {
+    if st == nil { return false }
+    path := st.path(h)
+    if _, err := os.Stat(path); err == nil { return true }
+    if st.used+int64(len(data)) > MaxStored { return false }
+    if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil { return false }
+    if err := os.WriteFile(path, st.Redactor.Bytes(data), 0o600); err != nil { return false }
+    st.used += int64(len(data))
+    return true
+}
+
+// Get returns the content stored for e, or an error if it was not kept.
+func (st *Store) Get(e *Entry) ([]byte, error) {
+    if e == nil { return nil, nil }
+    if !e.Stored { return nil, errors.New("content not captured") }
+    return os.ReadFile(st.path(e.Hash))
+}
+
+func (st *Store) path(h string) string {
+    return filepath.Join(st.Dir, h[:2], h[2:])
+}
@@ -0,0 +1,17 @@
+package trace
+
+import "time"
+
+// Exec is one program execution observed under a traced process.
+type Exec struct {
+    Time time.Time // process start for a new pid, else when the exec was seen
+    PID  int
+    PPID int
+    Exe  string // resolved binary path
+    Argv []string
+    Cwd  string
+}
+
+// DefaultInterval is how often the process tree is polled. Processes that
+// start and exit between two polls are not seen.
+const DefaultInterval = 100 * time.Millisecond
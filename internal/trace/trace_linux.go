@@ -0,0 +1,199 @@
+package trace
+
+import (
+    "bytes"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// clockTicks is USER_HZ, which is 100 on every mainstream Linux build.
+const clockTicks = 100
+
+// Tracer polls /proc for execs by root and its descendants. Polling needs no
+// privileges, works inside the restricted profile's namespaces and leaves
+// setuid programs working, but misses processes that start and exit between
+// two polls and ones that re-parent away from the tree.
+//
+// The tree is walked through /proc/<pid>/task/<tid>/children, so a poll
+// only reads the traced processes. Kernels without those files
+// (CONFIG_PROC_CHILDREN) have all of /proc scanned instead.
+type Tracer struct {
+    root     int
+    fn       func(Exec)
+    procs    map[int]procStat // stat is read once per pid
+    seen     map[int]string   // traced pid -> start time + cmdline
+    scanAll  bool             // no children files: scan every pid in /proc
+    bootTime time.Time
+    stop     chan struct{}
+    done     sync.WaitGroup
+}
+
+// Start reports every exec under root to fn, from a background goroutine,
+// until Stop is called. root itself is reported too.
+func Start(root int, interval time.Duration, fn func(Exec)) (*Tracer, error) {
+    if _, err := os.Stat("/proc/self/stat"); err != nil { return nil, err }
+    t := &Tracer{root: root, fn: fn, procs: map[int]procStat{}, seen: map[int]string{}, bootTime: bootTime(), stop: make(chan struct{})}
+    _, err := os.Stat(childrenPath(root, root))
+    t.scanAll = err != nil
+    t.done.Add(1)
+    go func() {
+        defer t.done.Done()
+        tick := time.NewTicker(interval)
+        defer tick.Stop()
+        for {
+            t.poll()
+            select {
+            case <-tick.C:
+            case <-t.stop:
+                return
+            }
+        }
+    }()
+    return t, nil
+}
+
+// Stop takes a last look at the tree and stops polling.
+func (t *Tracer) Stop() {
+    close(t.stop)
+    t.done.Wait()
+    t.poll()
+}
+
+type procStat struct {
+    ppid  int
+    start uint64 // clock ticks after boot
+}
+
+func (t *Tracer) poll() {
+    children := t.children()
+    if children == nil { return }
+
+    alive := map[int]bool{}
+    queue := []int{t.root}
+    for len(queue) > 0 {
+        pid := queue[0]
+        queue = queue[1:]
+        if alive[pid] { continue }
+        alive[pid] = true
+        queue = append(queue, children(pid)...)
+
+        st, ok := t.procs[pid]
+        if !ok {
+            // A pid's parent only changes when it is orphaned, which takes
+            // it out of the tree anyway, so stat is read once.
+            if st, ok = readStat(pid); !ok { continue }
+            t.procs[pid] = st
+        }
+        raw, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/cmdline")
+        if err != nil || len(raw) == 0 { continue } // exited, or a zombie
+        start := strconv.FormatUint(st.start, 10) + "\x00"
+        key := start + string(raw)
+        prev, known := t.seen[pid]
+        if prev == key { continue }
+        t.seen[pid] = key
+        if !known && pid != t.root {
+            // A fresh fork still runs its parent's program; wait for its exec.
+            if pk, ok := t.seen[st.ppid]; ok && strings.SplitN(pk, "\x00", 2)[1] == string(raw) { continue }
+        }
+        when := time.Now()
+        if !strings.HasPrefix(prev, start) {
+            when = t.bootTime.Add(time.Duration(st.start) * time.Second / clockTicks)
+        }
+        argv := strings.Split(string(bytes.TrimRight(raw, "\x00")), "\x00")
+        exe, _ := os.Readlink("/proc/" + strconv.Itoa(pid) + "/exe")
+        cwd, _ := os.Readlink("/proc/" + strconv.Itoa(pid) + "/cwd")
+        t.fn(Exec{Time: when, PID: pid, PPID: st.ppid, Exe: exe, Argv: argv, Cwd: cwd})
+    }
+    for pid := range t.seen {
+        if !alive[pid] { delete(t.seen, pid) }
+    }
+    if !t.scanAll {
+        for pid := range t.procs {
+            if !alive[pid] { delete(t.procs, pid) }
+        }
+    }
+}
+
+// children returns a function listing a pid's children, or nil once root
+// has exited.
+func (t *Tracer) children() func(pid int) []int {
+    if !t.scanAll {
+        if _, err := os.Stat("/proc/" + strconv.Itoa(t.root)); err != nil { return nil }
+        return readChildren
+    }
+    d, err := os.Open("/proc")
+    if err != nil { return nil }
+    names, _ := d.Readdirnames(-1)
+    d.Close()
+    present := make(map[int]bool, len(names))
+    kids := map[int][]int{}
+    for _, name := range names {
+        pid, err := strconv.Atoi(name)
+        if err != nil { continue }
+        st, ok := t.procs[pid]
+        if !ok {
+            if st, ok = readStat(pid); !ok { continue }
+            t.procs[pid] = st
+        }
+        present[pid] = true
+        kids[st.ppid] = append(kids[st.ppid], pid)
+    }
+    for pid := range t.procs {
+        if !present[pid] { delete(t.procs, pid) }
+    }
+    if !present[t.root] { return nil }
+    return func(pid int) []int { return kids[pid] }
+}
+
+func childrenPath(pid, tid int) string {
+    return "/proc/" + strconv.Itoa(pid) + "/task/" + strconv.Itoa(tid) + "/children"
+}
+
+// readChildren lists the children of every thread of pid.
+func readChildren(pid int) []int {
+    d, err := os.Open("/proc/" + strconv.Itoa(pid) + "/task")
+    if err != nil { return nil }
+    tids, _ := d.Readdirnames(-1)
+    d.Close()
+    var out []int
+    for _, name := range tids {
+        tid, err := strconv.Atoi(name)
+        if err != nil { continue }
+        b, err := os.ReadFile(childrenPath(pid, tid))
+        if err != nil { continue }
+        for _, f := range strings.Fields(string(b)) {
+            if child, err := strconv.Atoi(f); err == nil { out = append(out, child) }
+        }
+    }
+    return out
+}
+
+// readStat parses ppid and start time from /proc/<pid>/stat. The command
+// name may contain spaces and parens, so fields are counted from the last ')'.
+func readStat(pid int) (procStat, bool) {
+    b, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+    if err != nil { return procStat{}, false }
+    i := bytes.LastIndexByte(b, ')')
+    if i < 0 { return procStat{}, false }
+    f := strings.Fields(string(b[i+1:]))
+    if len(f) < 20 { return procStat{}, false }
+    ppid, err1 := strconv.Atoi(f[1])
+    start, err2 := strconv.ParseUint(f[19], 10, 64)
+    if err1 != nil || err2 != nil { return procStat{}, false }
+    return procStat{ppid: ppid, start: start}, true
+}
+
+// bootTime derives the boot time from /proc/uptime, which unlike btime in
+// /proc/stat has sub-second precision.
+func bootTime() time.Time {
+    b, err := os.ReadFile("/proc/uptime")
+    if err != nil { return time.Now() }
+    f := strings.Fields(string(b))
+    if len(f) == 0 { return time.Now() }
+    up, err := strconv.ParseFloat(f[0], 64)
+    if err != nil { return time.Now() }
+    return time.Now().Add(-time.Duration(up * float64(time.Second)))
+}
@@ -0,0 +1,39 @@
+package trace
+
+import (
+    "os/exec"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestTracerSeesDescendants(t *testing.T) {
+    other := exec.Command("sleep", "5")
+    if err := other.Start(); err != nil { t.Fatal(err) }
+    defer other.Process.Kill()
+
+    cmd := exec.Command("sh", "-c", "sleep 0.5; sh -c 'sleep 0.5'")
+    if err := cmd.Start(); err != nil { t.Fatal(err) }
+    var mu sync.Mutex
+    var got []Exec
+    tr, err := Start(cmd.Process.Pid, 20*time.Millisecond, func(e Exec) {
+        mu.Lock()
+        got = append(got, e)
+        mu.Unlock()
+    })
+    if err != nil { t.Fatal(err) }
+    if err := cmd.Wait(); err != nil { t.Fatal(err) }
+    tr.Stop()
+
+    if tr.scanAll { t.Log("no /proc/<pid>/task/<tid>/children here; scanned all of /proc") }
+    sleeps := 0
+    for _, e := range got {
+        if e.PID == other.Process.Pid { t.Errorf("reported a process outside the tree: %+v", e) }
+        if len(e.Argv) > 0 && e.Argv[0] == "sleep" {
+            sleeps++
+            if e.Exe == "" || e.Cwd == "" { t.Errorf("incomplete exec: %+v", e) }
+        }
+    }
+    if len(got) == 0 || got[0].PID != cmd.Process.Pid { t.Errorf("root not reported first: %+v", got) }
+    if sleeps != 2 { t.Errorf("saw %d sleeps, want 2 (one a grandchild): %+v", sleeps, got) }
+}
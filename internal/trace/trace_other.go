@@ -0,0 +1,18 @@
+//go:build !linux
+
+package trace
+
+import (
+    "fmt"
+    "runtime"
+    "time"
+)
+
+// Tracer is only implemented on Linux.
+type Tracer struct{}
+
+func Start(root int, interval time.Duration, fn func(Exec)) (*Tracer, error) {
+    return nil, fmt.Errorf("exec tracing is not supported on %s", runtime.GOOS)
+}
+
+func (t *Tracer) Stop() {}
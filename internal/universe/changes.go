@@ -0,0 +1,78 @@
+package universe
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "os"
+    "path/filepath"
+
+    "heimdal/internal/snapshot"
+)
+
+// ChangesFile lists the workdir files added, modified or deleted since the
+// session's first run there.
+const ChangesFile = "changes.json"
+
+// snapshotFiles caps how many workdir files a snapshot covers.
+const snapshotFiles = 50000
+
+func (s Session) snapshotDir() string { return filepath.Join(s.Dir, "snapshot") }
+
+// Store returns the session's store of snapshotted file contents.
+func (s Session) Store() *snapshot.Store {
+    return snapshot.NewStore(filepath.Join(s.snapshotDir(), "objects"), s.Redactor)
+}
+
+// baselineDir holds the snapshots of workdir. Each workdir a session runs
+// in (the checkout, its worktree, another cwd on resume) has its own.
+func (s Session) baselineDir(workdir string) string {
+    sum := sha256.Sum256([]byte(filepath.Clean(workdir)))
+    return filepath.Join(s.snapshotDir(), hex.EncodeToString(sum[:8]))
+}
+
+// Baseline records workdir as the baseline for RecordChanges, unless the
+// session already has one for it. Runs call it once their workdir is known.
+func (s Session) Baseline(workdir string) error {
+    path := filepath.Join(s.baselineDir(workdir), "start.json")
+    if _, err := os.Stat(path); err == nil { return nil }
+    snap := snapshot.Take(workdir, RepoFiles(workdir, snapshotFiles), nil, s.Store())
+    return snapshot.Save(path, snap)
+}
+
+// DropBaseline forgets workdir's snapshots, e.g. once a worktree is gone, so
+// that a new one at the same path starts afresh.
+func (s Session) DropBaseline(workdir string) error {
+    return os.RemoveAll(s.baselineDir(workdir))
+}
+
+// RecordChanges snapshots workdir again and writes what changed since its
+// baseline to changes.json. Without a baseline it records nothing.
+func (s Session) RecordChanges(workdir string) ([]snapshot.Change, error) {
+    dir := s.baselineDir(workdir)
+    start, err := snapshot.Load(filepath.Join(dir, "start.json"))
+    if errors.Is(err, os.ErrNotExist) { return nil, nil }
+    if err != nil { return nil, err }
+    endPath := filepath.Join(dir, "end.json")
+    prev, err := snapshot.Load(endPath)
+    if err != nil { prev = start } // first run: unchanged files keep the start hashes
+    end := snapshot.Take(workdir, RepoFiles(workdir, snapshotFiles), prev, s.Store())
+    if err := snapshot.Save(endPath, end); err != nil { return nil, err }
+    changes := snapshot.Compare(start, end)
+    b, err := json.MarshalIndent(changes, "", "  ")
+    if err != nil { return nil, err }
+    return changes, writeFile(filepath.Join(s.Dir, ChangesFile), string(b)+"\n")
+}
+
+// Changes loads the changeset written by RecordChanges.
+func (s Session) Changes() ([]snapshot.Change, error) {
+    b, err := os.ReadFile(filepath.Join(s.Dir, ChangesFile))
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, errors.New("no changes recorded for this session")
+    }
+    if err != nil { return nil, err }
+    var changes []snapshot.Change
+    if err := json.Unmarshal(b, &changes); err != nil { return nil, err }
+    return changes, nil
+}
@@ -0,0 +1,44 @@
+package universe
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+
+    "heimdal/internal/snapshot"
+)
+
+func write(t *testing.T, path, content string) {
+    t.Helper()
+    if err := os.WriteFile(path, []byte(content), 0o644); err != nil { t.Fatal(err) }
+}
+
+func TestBaselinePerWorkdir(t *testing.T) {
+    s := Session{ID: "test", Dir: t.TempDir()}
+    a, b := t.TempDir(), t.TempDir()
+    write(t, filepath.Join(a, "main.go"), "package main\n")
+    write(t, filepath.Join(b, "README"), "b\n")
+
+    // No baseline yet: nothing is recorded.
+    if changes, err := s.RecordChanges(a); err != nil || changes != nil { t.Fatalf("without baseline: %v, %v", changes, err) }
+
+    if err := s.Baseline(a); err != nil { t.Fatal(err) }
+    write(t, filepath.Join(a, "main.go"), "package main\n\nfunc main() {}\n")
+    // A second Baseline keeps the first one.
+    if err := s.Baseline(a); err != nil { t.Fatal(err) }
+    changes, err := s.RecordChanges(a)
+    if err != nil { t.Fatal(err) }
+    if len(changes) != 1 || changes[0].Path != "main.go" || changes[0].Kind != snapshot.Modified { t.Errorf("changes in a = %+v", changes) }
+
+    // Another workdir is compared against its own baseline, not a's.
+    if err := s.Baseline(b); err != nil { t.Fatal(err) }
+    write(t, filepath.Join(b, "new.txt"), "x\n")
+    changes, err = s.RecordChanges(b)
+    if err != nil { t.Fatal(err) }
+    if len(changes) != 1 || changes[0].Path != "new.txt" || changes[0].Kind != snapshot.Added { t.Errorf("changes in b = %+v", changes) }
+
+    // Dropping a baseline lets the next one start afresh.
+    if err := s.DropBaseline(a); err != nil { t.Fatal(err) }
+    if err := s.Baseline(a); err != nil { t.Fatal(err) }
+    if changes, err := s.RecordChanges(a); err != nil || len(changes) != 0 { t.Errorf("after re-baseline: %+v, %v", changes, err) }
+}
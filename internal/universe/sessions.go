@@ -0,0 +1,194 @@
+package universe
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+)
+
+// MetaFile holds per-session metadata inside the session dir.
+const MetaFile = "session.json"
+
+// Meta describes a session for `heimdal session ls/show`.
+type Meta struct {
+    ID       string     `json:"id"`
+    App      string     `json:"app,omitempty"`
+    Workdir  string     `json:"workdir,omitempty"`
+    Profile  string     `json:"profile,omitempty"`
+    Started  time.Time  `json:"started"`
+    Ended    *time.Time `json:"ended,omitempty"`
+    ExitCode *int       `json:"exit_code,omitempty"`
+    Signal   string     `json:"signal,omitempty"`
+    Runs     int        `json:"runs"`
+    TimedOut bool       `json:"timed_out,omitempty"` // the last run was stopped by a timeout
+    // Peak memory (bytes) and CPU time of the last run, when measured.
+    PeakMemory int64 `json:"peak_memory,omitempty"`
+    CPUMS      int64 `json:"cpu_ms,omitempty"`
+    // Checkpoint is the git commit of the workdir's repo taken before the
+    // session's first run, and CheckpointRepo the root of that repo. Workdir
+    // follows resumed runs, so rollback must not go by it.
+    Checkpoint     string `json:"checkpoint,omitempty"`
+    CheckpointRepo string `json:"checkpoint_repo,omitempty"`
+    // Worktree is the git worktree the app runs in, when isolated in one,
+    // and WorktreeRepo the root of the repository it belongs to.
+    Worktree     string `json:"worktree,omitempty"`
+    WorktreeRepo string `json:"worktree_repo,omitempty"`
+}
+
+// RunDir is where the app runs: its worktree if it has one, else Workdir.
+func (m Meta) RunDir() string {
+    if m.Worktree != "" { return m.Worktree }
+    return m.Workdir
+}
+
+// OpenSession returns an existing session by ID or unique ID prefix,
+// reusing its context dir as is.
+func OpenSession(workdir, id string) (Session, error) {
+    full, err := resolveID(workdir, id)
+    if err != nil { return Session{}, err }
+    root := filepath.Join(SessionsDir(workdir), full)
+    return Session{ID: full, Dir: root, ContextDir: filepath.Join(root, "context")}, nil
+}
+
+func resolveID(workdir, id string) (string, error) {
+    if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+        return "", fmt.Errorf("invalid session id %q", id)
+    }
+    base := SessionsDir(workdir)
+    if fi, err := os.Stat(filepath.Join(base, id)); err == nil && fi.IsDir() {
+        return id, nil
+    }
+    entries, err := os.ReadDir(base)
+    if err != nil && !os.IsNotExist(err) { return "", err }
+    var matches []string
+    for _, e := range entries {
+        if e.IsDir() && strings.HasPrefix(e.Name(), id) {
+            matches = append(matches, e.Name())
+        }
+    }
+    switch len(matches) {
+    case 0:
+        return "", fmt.Errorf("session not found: %s", id)
+    case 1:
+        return matches[0], nil
+    default:
+        return "", fmt.Errorf("session id %q is ambiguous (%d matches)", id, len(matches))
+    }
+}
+
+// LoadMeta reads session.json. Sessions created before metadata existed get
+// a Meta synthesized from the directory's modification time.
+func (s Session) LoadMeta() (Meta, error) {
+    b, err := os.ReadFile(filepath.Join(s.Dir, MetaFile))
+    if errors.Is(err, os.ErrNotExist) {
+        fi, serr := os.Stat(s.Dir)
+        if serr != nil { return Meta{}, serr }
+        return Meta{ID: s.ID, Started: fi.ModTime()}, nil
+    }
+    if err != nil { return Meta{}, err }
+    var m Meta
+    if err := json.Unmarshal(b, &m); err != nil {
+        return Meta{}, fmt.Errorf("%s: %w", MetaFile, err)
+    }
+    m.ID = s.ID
+    return m, nil
+}
+
+// SaveMeta writes session.json.
+func (s Session) SaveMeta(m Meta) error {
+    m.ID = s.ID
+    b, err := json.MarshalIndent(m, "", "  ")
+    if err != nil { return err }
+    return writeFile(filepath.Join(s.Dir, MetaFile), string(b)+"\n")
+}
+
+// Begin records the start of a run in this session (new or resumed).
+func (s Session) Begin(app, profile, workdir string) (Meta, error) {
+    m, err := s.LoadMeta()
+    if err != nil { return Meta{}, err }
+    if m.Runs == 0 { m.Started = time.Now() }
+    m.App = app
+    m.Profile = profile
+    m.Workdir = workdir
+    m.Ended = nil
+    m.ExitCode = nil
+    m.Signal = ""
+    m.TimedOut = false
+    m.PeakMemory, m.CPUMS = 0, 0
+    m.Runs++
+    return m, s.SaveMeta(m)
+}
+
+// End records the exit code (and fatal signal, if any) of the run started
+// with Begin.
+func (s Session) End(m Meta, code int, signal string) error {
+    now := time.Now()
+    m.Ended = &now
+    m.ExitCode = &code
+    m.Signal = signal
+    return s.SaveMeta(m)
+}
+
+// ListSessions returns all sessions, newest first.
+func ListSessions(workdir string) ([]Meta, error) {
+    base := SessionsDir(workdir)
+    entries, err := os.ReadDir(base)
+    if os.IsNotExist(err) { return nil, nil }
+    if err != nil { return nil, err }
+    var out []Meta
+    for _, e := range entries {
+        if !e.IsDir() { continue }
+        s := Session{ID: e.Name(), Dir: filepath.Join(base, e.Name())}
+        m, err := s.LoadMeta()
+        if err != nil { continue }
+        out = append(out, m)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Started.After(out[j].Started) })
+    return out, nil
+}
+
+// RemoveSession deletes a session dir by ID or unique prefix. cleanup, if
+// set, runs first and can veto the removal by returning an error.
+func RemoveSession(workdir, id string, cleanup func(Session, Meta) error) (string, error) {
+    s, err := OpenSession(workdir, id)
+    if err != nil { return "", err }
+    if cleanup != nil {
+        m, err := s.LoadMeta()
+        if err != nil { return s.ID, err }
+        if err := cleanup(s, m); err != nil { return s.ID, err }
+    }
+    return s.ID, os.RemoveAll(s.Dir)
+}
+
+// PruneSessions removes sessions started before now-olderThan and returns their
+// IDs. Sessions whose cleanup fails are kept; their errors are returned
+// together.
+func PruneSessions(workdir string, olderThan time.Duration, cleanup func(Session, Meta) error) ([]string, error) {
+    metas, err := ListSessions(workdir)
+    if err != nil { return nil, err }
+    cutoff := time.Now().Add(-olderThan)
+    var removed []string
+    var errs []error
+    for _, m := range metas {
+        last := m.Started
+        if m.Ended != nil { last = *m.Ended }
+        if !last.Before(cutoff) { continue }
+        dir := filepath.Join(SessionsDir(workdir), m.ID)
+        if cleanup != nil {
+            if err := cleanup(Session{ID: m.ID, Dir: dir, ContextDir: filepath.Join(dir, "context")}, m); err != nil {
+                errs = append(errs, err)
+                continue
+            }
+        }
+        if err := os.RemoveAll(dir); err != nil {
+            return removed, err
+        }
+        removed = append(removed, m.ID)
+    }
+    return removed, errors.Join(errs...)
+}
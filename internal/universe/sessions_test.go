@@ -0,0 +1,42 @@
+package universe
+
+import (
+    "errors"
+    "os"
+    "testing"
+    "time"
+)
+
+func TestRemoveSessionCleanup(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    workdir := t.TempDir()
+    start := func(worktree string) Session {
+        s, err := StartSession(workdir, nil)
+        if err != nil { t.Fatal(err) }
+        m, err := s.Begin("demo", "permissive", workdir)
+        if err != nil { t.Fatal(err) }
+        m.Worktree = worktree
+        m.Started = time.Now().Add(-48 * time.Hour)
+        if err := s.End(m, 0, ""); err != nil { t.Fatal(err) }
+        return s
+    }
+    plain, kept := start(""), start("/repo/wt")
+    veto := errors.New("branch has unmerged commits")
+    cleanup := func(s Session, m Meta) error {
+        if m.Worktree != "" { return veto }
+        return nil
+    }
+
+    if _, err := RemoveSession(workdir, kept.ID, cleanup); !errors.Is(err, veto) { t.Errorf("RemoveSession = %v, want the cleanup error", err) }
+    if _, err := os.Stat(kept.Dir); err != nil { t.Errorf("vetoed session was removed: %v", err) }
+
+    // End stamps the time, so prune everything ended before now.
+    time.Sleep(10 * time.Millisecond)
+    removed, err := PruneSessions(workdir, time.Millisecond, cleanup)
+    if !errors.Is(err, veto) { t.Errorf("PruneSessions error = %v, want the cleanup error", err) }
+    if len(removed) != 1 || removed[0] != plain.ID { t.Errorf("pruned %v, want only %s", removed, plain.ID) }
+    if _, err := os.Stat(kept.Dir); err != nil { t.Errorf("vetoed session was pruned: %v", err) }
+
+    if _, err := RemoveSession(workdir, kept.ID, nil); err != nil { t.Fatal(err) }
+    if _, err := os.Stat(kept.Dir); !os.IsNotExist(err) { t.Errorf("session not removed: %v", err) }
+}
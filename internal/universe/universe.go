@@ -9,6 +9,8 @@ import (
     "path/filepath"
     "strings"
     "time"
+
+    "heimdal/internal/redact"
 )
 
 // Session holds info about a Heimdal universe session.
@@ -16,32 +18,63 @@ type Session struct {
     ID         string
     Dir        string // root dir for session data
     ContextDir string // where context files live
+    // Redactor, when set, masks secrets in context files as they are written.
+    Redactor *redact.Redactor
 }
 
-// StartSession creates a session directory and basic context files.
+// StartSession creates a session directory and basic context files, masking
+// secrets known to red (which may be nil).
 // If home is available, uses $HOME/.heimdall/sessions; otherwise uses CWD.
-func StartSession(workdir string) (Session, error) {
+func StartSession(workdir string, red *redact.Redactor) (Session, error) {
     sid := newID()
-    base := ""
-    if h, err := os.UserHomeDir(); err == nil {
-        base = filepath.Join(h, ".heimdall", "sessions")
-    } else {
-        base = filepath.Join(workdir, ".heimdall-sessions")
-    }
-    root := filepath.Join(base, sid)
-    ctxDir := filepath.Join(root, "context")
-    if err := os.MkdirAll(ctxDir, 0o755); err != nil {
+    root := filepath.Join(SessionsDir(workdir), sid)
+    s := Session{ID: sid, Dir: root, ContextDir: filepath.Join(root, "context"), Redactor: red}
+    if err := os.MkdirAll(s.ContextDir, 0o755); err != nil {
         return Session{}, err
     }
     // Write minimal context
-    if err := writeFile(filepath.Join(ctxDir, "system.md"), systemBanner()); err != nil {
+    if err := s.WriteContext("system.md", systemBanner()); err != nil {
         return Session{}, err
     }
     // Repo file index (limited)
-    _ = writeRepoIndex(ctxDir, workdir)
+    _ = s.writeRepoIndex(workdir)
     // Docs index
-    _ = writeDocsIndex(ctxDir, workdir)
-    return Session{ID: sid, Dir: root, ContextDir: ctxDir}, nil
+    _ = s.writeDocsIndex(workdir)
+    return s, nil
+}
+
+// SessionsDir returns the directory holding all session dirs.
+func SessionsDir(workdir string) string {
+    if h, err := os.UserHomeDir(); err == nil {
+        return filepath.Join(h, ".heimdall", "sessions")
+    }
+    return filepath.Join(workdir, ".heimdall-sessions")
+}
+
+// Latest returns the ID of the session whose file named name (e.g. audit.jsonl)
+// was modified most recently.
+func Latest(workdir, name string) (string, error) {
+    base := SessionsDir(workdir)
+    entries, err := os.ReadDir(base)
+    if err != nil { return "", err }
+    var best string
+    var bestMod time.Time
+    for _, e := range entries {
+        if !e.IsDir() { continue }
+        fi, err := os.Stat(filepath.Join(base, e.Name(), name))
+        if err != nil { continue }
+        if best == "" || fi.ModTime().After(bestMod) {
+            best = e.Name()
+            bestMod = fi.ModTime()
+        }
+    }
+    if best == "" { return "", fmt.Errorf("no sessions with %s in %s", name, base) }
+    return best, nil
+}
+
+// WriteContext writes (or replaces) a file in the session's context dir.
+func (s Session) WriteContext(name, content string) error {
+    return writeFile(filepath.Join(s.ContextDir, name), s.Redactor.String(content))
 }
 
 func newID() string {
@@ -61,10 +94,10 @@ func systemBanner() string {
     return "# Heimdal Universe\n\nThis session runs inside the Heimdal OS wrapper.\n\n" + time.Now().Format(time.RFC3339) + "\n"
 }
 
-func writeRepoIndex(ctxDir, workdir string) error {
+// RepoFiles lists up to max files under workdir (relative, slash paths in
+// walk order), skipping VCS, dependency and build dirs and heimdal's own files.
+func RepoFiles(workdir string, max int) []string {
     var files []string
-    // Walk but limit count and size
-    max := 500
     _ = filepath.WalkDir(workdir, func(path string, d fs.DirEntry, err error) error {
         if err != nil { return nil }
         // skip hidden heavy folders
@@ -78,16 +111,20 @@ func writeRepoIndex(ctxDir, workdir string) error {
         }
         rel, _ := filepath.Rel(workdir, path)
         if strings.HasPrefix(rel, ".heimdall") { return nil }
-        files = append(files, rel)
-        if len(files) >= max { return fmt.Errorf("limit") }
+        files = append(files, filepath.ToSlash(rel))
+        if len(files) >= max { return fs.SkipAll }
         return nil
     })
-    // ignore limit error
+    return files
+}
+
+func (s Session) writeRepoIndex(workdir string) error {
+    files := RepoFiles(workdir, 500)
     content := "# Repo files (truncated)\n" + strings.Join(files, "\n") + "\n"
-    return writeFile(filepath.Join(ctxDir, "repo_files.txt"), content)
+    return s.WriteContext("repo_files.txt", content)
 }
 
-func writeDocsIndex(ctxDir, workdir string) error {
+func (s Session) writeDocsIndex(workdir string) error {
     docs := filepath.Join(workdir, "docs")
     entries, err := os.ReadDir(docs)
     if err != nil { return nil }
@@ -96,6 +133,6 @@ func writeDocsIndex(ctxDir, workdir string) error {
     for _, e := range entries {
         lines = append(lines, e.Name())
     }
-    return writeFile(filepath.Join(ctxDir, "docs_files.txt"), strings.Join(lines, "\n")+"\n")
+    return s.WriteContext("docs_files.txt", strings.Join(lines, "\n")+"\n")
 }
 
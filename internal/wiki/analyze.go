@@ -0,0 +1,89 @@
+package wiki
+
+import (
+    "strings"
+    "unicode"
+)
+
+// tokenize lowercases s and splits it into word tokens on anything that is
+// not a letter or digit ("ai-os" -> "ai", "os").
+func tokenize(s string) []string {
+    return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+}
+
+// analyze turns text into index terms: tokenize, drop stop-words, stem.
+// Positions are kept (stop-words leave a gap) so phrases can be matched.
+func analyze(s string) []string {
+    toks := tokenize(s)
+    out := make([]string, len(toks))
+    for i, t := range toks {
+        if stopWords[t] { continue }
+        out[i] = stem(t)
+    }
+    return out
+}
+
+var stopWords = func() map[string]bool {
+    m := map[string]bool{}
+    for _, w := range strings.Fields(`a an and are as at be but by can do for from has have how
+        i if in into is it its of on or so such that the their then there these they this to
+        was we were what when where which who will with you your`) {
+        m[w] = true
+    }
+    return m
+}()
+
+// stem is a light suffix-stripping stemmer (roughly Porter step 1 plus common
+// derivational endings). It only needs to map related word forms to the same
+// term consistently for indexing and querying, not to produce real words.
+func stem(w string) string {
+    if len(w) <= 3 || !isAlpha(w) { return w }
+    // plurals
+    switch {
+    case strings.HasSuffix(w, "sses"):
+        w = w[:len(w)-2]
+    case strings.HasSuffix(w, "ies"):
+        w = w[:len(w)-3] + "y"
+    case strings.HasSuffix(w, "ss"), strings.HasSuffix(w, "us"), strings.HasSuffix(w, "is"):
+    case strings.HasSuffix(w, "s"):
+        w = w[:len(w)-1]
+    }
+    // -ed / -ing, only when a vowel remains in the stem
+    for _, suf := range []string{"ing", "ed"} {
+        if strings.HasSuffix(w, suf) && hasVowel(w[:len(w)-len(suf)]) && len(w)-len(suf) >= 3 {
+            w = w[:len(w)-len(suf)]
+            if n := len(w); n >= 2 && w[n-1] == w[n-2] && !strings.ContainsRune("lsz", rune(w[n-1])) {
+                w = w[:n-1] // running -> run
+            }
+            break
+        }
+    }
+    // derivational endings
+    for _, r := range [][2]string{
+        {"ational", "ate"}, {"ization", "ize"}, {"fulness", "ful"}, {"iveness", "ive"},
+        {"ation", "ate"}, {"ness", ""}, {"ment", ""}, {"ly", ""}, {"er", ""},
+    } {
+        if strings.HasSuffix(w, r[0]) && len(w)-len(r[0]) >= 3 {
+            w = w[:len(w)-len(r[0])] + r[1]
+            break
+        }
+    }
+    // normalize a trailing -e / -y so "configure", "configur(ed)" and "configur(ing)" meet
+    if n := len(w); n > 3 && (w[n-1] == 'e' || w[n-1] == 'y') {
+        w = w[:n-1]
+    }
+    return w
+}
+
+func isAlpha(s string) bool {
+    for _, r := range s {
+        if r < 'a' || r > 'z' { return false }
+    }
+    return true
+}
+
+func hasVowel(s string) bool {
+    return strings.ContainsAny(s, "aeiouy")
+}
@@ -0,0 +1,60 @@
+package wiki
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestStem(t *testing.T) {
+    tests := []struct {
+        words []string // forms that must share a stem
+        want  string
+    }{
+        {[]string{"setup", "setups"}, "setup"},
+        {[]string{"setting", "settings"}, "set"},
+        {[]string{"configure", "configured", "configuring"}, "configur"},
+        {[]string{"run", "runs", "running"}, "run"},
+        {[]string{"install", "installed", "installing"}, "install"},
+        {[]string{"sandbox", "sandboxes", "sandboxing"}, "sandbox"},
+        {[]string{"cache", "cached", "caching"}, "cach"},
+        {[]string{"policy", "policies"}, "polic"},
+        {[]string{"hope", "hoped"}, "hop"},
+        {[]string{"relate", "relational"}, "relat"},
+        {[]string{"class", "classes"}, "class"},
+        // -ss, -us and -is are not plurals.
+        {[]string{"status"}, "status"},
+        {[]string{"analysis"}, "analysis"},
+        // Doubled consonants are undone, except l, s and z.
+        {[]string{"hopping"}, "hop"},
+        {[]string{"filled"}, "fill"},
+        {[]string{"buzzing"}, "buzz"},
+        // Derivational endings.
+        {[]string{"kindness"}, "kind"},
+        {[]string{"quickly"}, "quick"},
+        {[]string{"fast", "faster"}, "fast"},
+        // Short words and anything not purely a-z are left alone.
+        {[]string{"cli"}, "cli"},
+        {[]string{"ls"}, "ls"},
+        {[]string{"v2"}, "v2"},
+        {[]string{"mcp"}, "mcp"},
+    }
+    for _, tt := range tests {
+        for _, w := range tt.words {
+            if got := stem(w); got != tt.want { t.Errorf("stem(%q) = %q, want %q", w, got, tt.want) }
+        }
+    }
+}
+
+func TestAnalyze(t *testing.T) {
+    tests := []struct {
+        in   string
+        want []string
+    }{
+        {"How to set up the Claude CLI", []string{"", "", "set", "up", "", "claud", "cli"}},
+        {"ai-os: Sandboxing!", []string{"ai", "os", "sandbox"}},
+        {"", []string{}},
+    }
+    for _, tt := range tests {
+        if got := analyze(tt.in); !reflect.DeepEqual(got, tt.want) { t.Errorf("analyze(%q) = %q, want %q", tt.in, got, tt.want) }
+    }
+}
@@ -0,0 +1,47 @@
+package wiki
+
+import (
+    "fmt"
+    "strings"
+)
+
+// ApproxTokens estimates the token count of s (about four bytes per token).
+func ApproxTokens(s string) int {
+    return (len(s) + 3) / 4
+}
+
+// SelectContext ranks pages against query and packs the best maxPages of
+// them into a Markdown document of at most maxTokens. Pages that do not fit
+// are skipped; the first page is truncated rather than dropped. It returns
+// the document and the titles it includes.
+func SelectContext(db DB, query string, maxPages, maxTokens int) (string, []string) {
+    results := Search(db, query, maxPages)
+    if len(results) == 0 { return "", nil }
+    var b strings.Builder
+    fmt.Fprintf(&b, "# Wiki context\n\nPages from the Heimdal wiki relevant to: %s\n", query)
+    budget := maxTokens - ApproxTokens(b.String())
+    var titles []string
+    for _, r := range results {
+        p, ok := Show(db, r.Title)
+        if !ok { continue }
+        body := strings.TrimSpace(p.Content)
+        if first, rest, _ := strings.Cut(body, "\n"); strings.TrimSpace(strings.TrimPrefix(first, "# ")) == p.Title {
+            body = strings.TrimSpace(rest) // drop an H1 repeating the title
+        }
+        section := fmt.Sprintf("\n## %s\n\n%s\n", p.Title, body)
+        cost := ApproxTokens(section)
+        if cost > budget {
+            if len(titles) > 0 { continue }
+            // Always give the best page, cut to the budget.
+            cut := budget * 4
+            if cut <= 0 { break }
+            section = section[:runeStart(section, cut)] + "\n…\n"
+            cost = budget
+        }
+        b.WriteString(section)
+        budget -= cost
+        titles = append(titles, p.Title)
+    }
+    if len(titles) == 0 { return "", nil }
+    return b.String(), titles
+}
@@ -0,0 +1,137 @@
+package wiki
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash/fnv"
+    "math"
+    "os/exec"
+    "strings"
+)
+
+// Embedder turns texts into fixed-size vectors for semantic search.
+// Implementations must be deterministic so persisted vectors stay valid.
+type Embedder interface {
+    // Name identifies the embedder and its settings; a persisted index built
+    // by a different Name is rebuilt.
+    Name() string
+    Embed(texts []string) ([][]float32, error)
+}
+
+// NewEmbedder returns the embedder for a config spec: "hash" (the built-in
+// default) or "cmd:<command> [args...]" for an external offline model.
+func NewEmbedder(spec string) (Embedder, error) {
+    spec = strings.TrimSpace(spec)
+    switch {
+    case spec == "" || spec == "hash":
+        return HashEmbedder{Dim: 512}, nil
+    case strings.HasPrefix(spec, "cmd:"):
+        argv := strings.Fields(strings.TrimPrefix(spec, "cmd:"))
+        if len(argv) == 0 { return nil, errors.New("embedder cmd: missing command") }
+        return CommandEmbedder{Argv: argv}, nil
+    default:
+        return nil, fmt.Errorf("unknown embedder %q (want hash or cmd:<command>)", spec)
+    }
+}
+
+// HashEmbedder is a dependency-free embedder: analyzed terms, term bigrams
+// and character trigrams are feature-hashed into Dim signed buckets with
+// sublinear TF weighting, then L2-normalized. It captures word overlap
+// across inflections and partial words, not true synonyms.
+type HashEmbedder struct {
+    Dim int
+}
+
+func (h HashEmbedder) Name() string { return fmt.Sprintf("hash-v1-%d", h.Dim) }
+
+func (h HashEmbedder) Embed(texts []string) ([][]float32, error) {
+    out := make([][]float32, len(texts))
+    for i, t := range texts {
+        out[i] = h.embed(t)
+    }
+    return out, nil
+}
+
+func (h HashEmbedder) embed(text string) []float32 {
+    counts := map[string]float64{}
+    terms := analyze(text)
+    prev := ""
+    for _, t := range terms {
+        if t == "" {
+            prev = ""
+            continue
+        }
+        counts["w:"+t] += 1
+        if prev != "" { counts["b:"+prev+" "+t] += 0.5 }
+        prev = t
+        padded := "^" + t + "$"
+        for j := 0; j+3 <= len(padded); j++ {
+            counts["c:"+padded[j:j+3]] += 0.25
+        }
+    }
+    vec := make([]float32, h.Dim)
+    for feat, c := range counts {
+        f := fnv.New64a()
+        f.Write([]byte(feat))
+        sum := f.Sum64()
+        idx := int(sum % uint64(h.Dim))
+        sign := float32(1)
+        if sum>>63 == 1 { sign = -1 }
+        vec[idx] += sign * float32(1+math.Log(c+1))
+    }
+    normalize(vec)
+    return vec
+}
+
+// CommandEmbedder delegates to an external program, e.g. a local
+// sentence-transformer wrapper. It writes {"texts": [...]} as JSON to the
+// program's stdin and expects {"vectors": [[...], ...]} on stdout.
+type CommandEmbedder struct {
+    Argv []string
+}
+
+func (c CommandEmbedder) Name() string { return "cmd:" + strings.Join(c.Argv, " ") }
+
+func (c CommandEmbedder) Embed(texts []string) ([][]float32, error) {
+    in, err := json.Marshal(struct {
+        Texts []string `json:"texts"`
+    }{texts})
+    if err != nil { return nil, err }
+    cmd := exec.Command(c.Argv[0], c.Argv[1:]...)
+    cmd.Stdin = bytes.NewReader(in)
+    var stderr bytes.Buffer
+    cmd.Stderr = &stderr
+    outb, err := cmd.Output()
+    if err != nil {
+        return nil, fmt.Errorf("embedder %s: %w: %s", c.Argv[0], err, strings.TrimSpace(stderr.String()))
+    }
+    var resp struct {
+        Vectors [][]float32 `json:"vectors"`
+    }
+    if err := json.Unmarshal(outb, &resp); err != nil {
+        return nil, fmt.Errorf("embedder %s: decode output: %w", c.Argv[0], err)
+    }
+    if len(resp.Vectors) != len(texts) {
+        return nil, fmt.Errorf("embedder %s: got %d vectors for %d texts", c.Argv[0], len(resp.Vectors), len(texts))
+    }
+    for _, v := range resp.Vectors { normalize(v) }
+    return resp.Vectors, nil
+}
+
+func normalize(v []float32) {
+    var sum float64
+    for _, x := range v { sum += float64(x) * float64(x) }
+    if sum == 0 { return }
+    n := float32(math.Sqrt(sum))
+    for i := range v { v[i] /= n }
+}
+
+// cosine assumes both vectors are normalized.
+func cosine(a, b []float32) float64 {
+    if len(a) != len(b) { return 0 }
+    var dot float64
+    for i := range a { dot += float64(a[i]) * float64(b[i]) }
+    return dot
+}
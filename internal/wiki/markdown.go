@@ -0,0 +1,95 @@
+package wiki
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// frontMatter is the YAML header of a Markdown page.
+type frontMatter struct {
+    Title   string   `yaml:"title"`
+    Tags    []string `yaml:"tags"`
+    Aliases []string `yaml:"aliases"`
+}
+
+// LoadDir reads every .md file under dir (recursively) as a page.
+func LoadDir(dir string) ([]Page, error) {
+    var paths []string
+    err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+        if err != nil { return err }
+        if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".md") {
+            paths = append(paths, path)
+        }
+        return nil
+    })
+    if err != nil { return nil, err }
+    sort.Strings(paths)
+    pages := make([]Page, 0, len(paths))
+    for _, path := range paths {
+        b, err := os.ReadFile(path)
+        if err != nil { return nil, err }
+        p, err := ParseMarkdown(b, path)
+        if err != nil { return nil, err }
+        pages = append(pages, p)
+    }
+    return pages, nil
+}
+
+// ParseMarkdown parses a page with optional front-matter:
+//
+//  ---
+//  title: Claude Code
+//  tags: [ai, claude]
+//  aliases: [claude]
+//  ---
+//  # body...
+//
+// Without a title, the first "# " heading or the file name is used.
+func ParseMarkdown(b []byte, path string) (Page, error) {
+    b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+    var fm frontMatter
+    body := b
+    if bytes.HasPrefix(b, []byte("---\n")) {
+        rest := b[len("---\n"):]
+        end := bytes.Index(rest, []byte("\n---"))
+        if end < 0 {
+            return Page{}, fmt.Errorf("%s: unterminated front-matter", path)
+        }
+        // Leading newline keeps yaml's line numbers matching the file.
+        dec := yaml.NewDecoder(bytes.NewReader(append([]byte("\n"), rest[:end+1]...)))
+        dec.KnownFields(true)
+        if err := dec.Decode(&fm); err != nil && !errors.Is(err, io.EOF) {
+            return Page{}, fmt.Errorf("%s: front-matter: %w", path, err)
+        }
+        body = rest[end+len("\n---"):]
+        if i := bytes.IndexByte(body, '\n'); i >= 0 {
+            body = body[i+1:]
+        } else {
+            body = nil
+        }
+    }
+    content := strings.TrimSpace(string(body))
+    title := fm.Title
+    if title == "" {
+        for _, line := range strings.Split(content, "\n") {
+            if strings.HasPrefix(line, "# ") {
+                title = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+                break
+            }
+        }
+    }
+    if title == "" {
+        base := filepath.Base(path)
+        title = strings.TrimSuffix(base, filepath.Ext(base))
+    }
+    return Page{Title: title, Tags: fm.Tags, Aliases: fm.Aliases, Content: content, Source: path}, nil
+}
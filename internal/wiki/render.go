@@ -0,0 +1,108 @@
+package wiki
+
+import (
+    "fmt"
+    "io"
+    "regexp"
+    "strings"
+)
+
+// ANSI styles used by Render when color is enabled.
+const (
+    ansiReset     = "\x1b[0m"
+    ansiBold      = "\x1b[1m"
+    ansiDim       = "\x1b[2m"
+    ansiUnderline = "\x1b[4m"
+    ansiCyan      = "\x1b[36m"
+    ansiYellow    = "\x1b[33m"
+)
+
+var (
+    headingRe = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+    bulletRe  = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+    orderedRe = regexp.MustCompile(`^(\s*)(\d+)[.)]\s+(.*)$`)
+    inlineRe  = regexp.MustCompile("`[^`]+`|\\*\\*[^*]+\\*\\*")
+    linkRe    = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+)
+
+// Render writes Markdown content formatted for a terminal: headings, bullet
+// and numbered lists, fenced code blocks, inline code, bold and links.
+// Without color, structure is kept with plain-text markers.
+func Render(w io.Writer, content string, color bool) {
+    style := func(s, codes string) string {
+        if !color { return s }
+        return codes + s + ansiReset
+    }
+    inline := func(s string) string {
+        s = linkRe.ReplaceAllString(s, "$1 <$2>")
+        return inlineRe.ReplaceAllStringFunc(s, func(m string) string {
+            if strings.HasPrefix(m, "`") {
+                return style(strings.Trim(m, "`"), ansiYellow)
+            }
+            return style(strings.Trim(m, "*"), ansiBold)
+        })
+    }
+
+    inCode := false
+    for _, line := range strings.Split(content, "\n") {
+        trimmed := strings.TrimSpace(line)
+        if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+            inCode = !inCode
+            if inCode {
+                if lang := strings.TrimLeft(trimmed, "`~"); lang != "" {
+                    fmt.Fprintln(w, style("    ["+lang+"]", ansiDim))
+                }
+            }
+            continue
+        }
+        if inCode {
+            fmt.Fprintln(w, style("    "+line, ansiYellow))
+            continue
+        }
+        if m := headingRe.FindStringSubmatch(line); m != nil {
+            raw := m[2]
+            // Upper-case before styling: "\x1b[1m" upper-cased is "\x1b[1M",
+            // which deletes a terminal line.
+            if len(m[1]) == 1 { raw = upperExceptLinks(raw) }
+            text := inline(raw)
+            switch len(m[1]) {
+            case 1:
+                fmt.Fprintln(w, style(text, ansiBold+ansiUnderline+ansiCyan))
+                if !color { fmt.Fprintln(w, strings.Repeat("=", len([]rune(text)))) }
+            case 2:
+                fmt.Fprintln(w, style(text, ansiBold+ansiCyan))
+                if !color { fmt.Fprintln(w, strings.Repeat("-", len([]rune(text)))) }
+            default:
+                fmt.Fprintln(w, style(text, ansiBold))
+            }
+            continue
+        }
+        if m := bulletRe.FindStringSubmatch(line); m != nil {
+            fmt.Fprintf(w, "%s  %s %s\n", m[1], style("•", ansiCyan), inline(m[2]))
+            continue
+        }
+        if m := orderedRe.FindStringSubmatch(line); m != nil {
+            fmt.Fprintf(w, "%s  %s %s\n", m[1], style(m[2]+".", ansiCyan), inline(m[3]))
+            continue
+        }
+        if strings.HasPrefix(trimmed, ">") {
+            fmt.Fprintln(w, style("  │ "+inline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))), ansiDim))
+            continue
+        }
+        fmt.Fprintln(w, inline(line))
+    }
+}
+
+// upperExceptLinks upper-cases s except for link targets, which may be
+// case-sensitive URLs or paths.
+func upperExceptLinks(s string) string {
+    var b strings.Builder
+    last := 0
+    for _, loc := range linkRe.FindAllStringSubmatchIndex(s, -1) {
+        b.WriteString(strings.ToUpper(s[last:loc[4]]))
+        b.WriteString(s[loc[4]:loc[5]])
+        last = loc[5]
+    }
+    b.WriteString(strings.ToUpper(s[last:]))
+    return b.String()
+}
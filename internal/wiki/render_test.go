@@ -0,0 +1,33 @@
+package wiki
+
+import (
+    "regexp"
+    "strings"
+    "testing"
+)
+
+// csiRe matches ANSI CSI sequences; Render may only emit SGR ones (final m).
+var csiRe = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+func TestRenderHeadings(t *testing.T) {
+    tests := []struct {
+        in    string
+        color bool
+        want  string // the first line, escapes stripped
+    }{
+        {"# Set up `claude` with **care**", true, "SET UP CLAUDE WITH CARE"},
+        {"# Set up `claude` with **care**", false, "SET UP CLAUDE WITH CARE"},
+        {"# See [the Docs](https://example.com/Docs/Setup)", false, "SEE THE DOCS <https://example.com/Docs/Setup>"},
+        {"## Keep `Case`", true, "Keep Case"},
+    }
+    for _, tt := range tests {
+        var b strings.Builder
+        Render(&b, tt.in, tt.color)
+        out := b.String()
+        for _, seq := range csiRe.FindAllString(out, -1) {
+            if !strings.HasSuffix(seq, "m") { t.Errorf("Render(%q) emits non-SGR sequence %q", tt.in, seq) }
+        }
+        first := strings.SplitN(csiRe.ReplaceAllString(out, ""), "\n", 2)[0]
+        if first != tt.want { t.Errorf("Render(%q, %v) = %q, want %q", tt.in, tt.color, first, tt.want) }
+    }
+}
@@ -0,0 +1,222 @@
+package wiki
+
+import (
+    "math"
+    "regexp"
+    "strings"
+    "unicode/utf8"
+)
+
+// BM25 parameters.
+const (
+    bm25K1 = 1.2
+    bm25B  = 0.75
+)
+
+// Indexed fields and their boosts: a hit in a title (or alias) outweighs one
+// in content.
+const (
+    fieldTitle = iota
+    fieldTags
+    fieldContent
+    numFields
+)
+
+var fieldBoost = [numFields]float64{fieldTitle: 3, fieldTags: 2, fieldContent: 1}
+
+type doc struct {
+    terms [numFields][]string // analyzed terms by position ("" for stop-words)
+    tf    [numFields]map[string]int
+}
+
+// Index is an inverted index over wiki pages scored with BM25F.
+type Index struct {
+    pages  []Page
+    docs   []doc
+    df     map[string]int // number of docs containing a term in any field
+    avgLen [numFields]float64
+}
+
+// NewIndex analyzes every page of db.
+func NewIndex(db DB) *Index {
+    ix := &Index{pages: db.Pages, df: map[string]int{}}
+    var total [numFields]int
+    for _, p := range db.Pages {
+        var d doc
+        d.terms[fieldTitle] = analyze(p.Title + " " + strings.Join(p.Aliases, " "))
+        d.terms[fieldTags] = analyze(strings.Join(p.Tags, " "))
+        d.terms[fieldContent] = analyze(p.Content)
+        seen := map[string]bool{}
+        for f := 0; f < numFields; f++ {
+            d.tf[f] = map[string]int{}
+            for _, t := range d.terms[f] {
+                if t == "" { continue }
+                d.tf[f][t]++
+                total[f]++
+                if !seen[t] {
+                    seen[t] = true
+                    ix.df[t]++
+                }
+            }
+        }
+        ix.docs = append(ix.docs, d)
+    }
+    for f := 0; f < numFields; f++ {
+        if len(ix.docs) > 0 { ix.avgLen[f] = float64(total[f]) / float64(len(ix.docs)) }
+    }
+    return ix
+}
+
+type query struct {
+    terms   []string   // distinct analyzed terms, phrases included
+    phrases [][]string // analyzed phrases that must all match
+    words   []string   // raw lowercase words, for snippets
+}
+
+var phraseRe = regexp.MustCompile(`"([^"]*)"`)
+
+// parseQuery splits a query into free terms and "quoted phrases".
+func parseQuery(q string) query {
+    var out query
+    seen := map[string]bool{}
+    addTerms := func(ts []string) {
+        for _, t := range ts {
+            if t != "" && !seen[t] {
+                seen[t] = true
+                out.terms = append(out.terms, t)
+            }
+        }
+    }
+    for _, m := range phraseRe.FindAllStringSubmatch(q, -1) {
+        ph := trimBlank(analyze(m[1]))
+        if len(ph) > 0 {
+            out.phrases = append(out.phrases, ph)
+            addTerms(ph)
+        }
+        out.words = append(out.words, strings.ToLower(strings.TrimSpace(m[1])))
+    }
+    rest := phraseRe.ReplaceAllString(q, " ")
+    addTerms(analyze(strings.ReplaceAll(rest, `"`, " ")))
+    for _, w := range tokenize(rest) {
+        if !stopWords[w] { out.words = append(out.words, w) }
+    }
+    return out
+}
+
+// trimBlank drops leading/trailing stop-word gaps from a phrase.
+func trimBlank(ts []string) []string {
+    for len(ts) > 0 && ts[0] == "" { ts = ts[1:] }
+    for len(ts) > 0 && ts[len(ts)-1] == "" { ts = ts[:len(ts)-1] }
+    return ts
+}
+
+func (ix *Index) idf(t string) float64 {
+    n := float64(len(ix.docs))
+    df := float64(ix.df[t])
+    return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+func (ix *Index) score(d doc, terms []string) float64 {
+    score := 0.0
+    for _, t := range terms {
+        tfw := 0.0
+        for f := 0; f < numFields; f++ {
+            tf := d.tf[f][t]
+            if tf == 0 { continue }
+            norm := 1.0
+            if ix.avgLen[f] > 0 {
+                norm = 1 - bm25B + bm25B*float64(len(d.terms[f]))/ix.avgLen[f]
+            }
+            tfw += fieldBoost[f] * float64(tf) / norm
+        }
+        if tfw == 0 { continue }
+        score += ix.idf(t) * tfw * (bm25K1 + 1) / (bm25K1 + tfw)
+    }
+    return score
+}
+
+// hasPhrase reports whether ph occurs as consecutive terms in any field.
+// A "" in ph (a stop-word) must line up with a stop-word in the text.
+func hasPhrase(d doc, ph []string) bool {
+    for f := 0; f < numFields; f++ {
+        ts := d.terms[f]
+        for i := 0; i+len(ph) <= len(ts); i++ {
+            ok := true
+            for j, t := range ph {
+                if ts[i+j] != t {
+                    ok = false
+                    break
+                }
+            }
+            if ok { return true }
+        }
+    }
+    return false
+}
+
+// Search ranks pages against query. Free terms are OR'ed and scored with
+// BM25F; every "quoted phrase" must appear in a matching page.
+func (ix *Index) Search(q string, limit int) []Result {
+    pq := parseQuery(q)
+    if len(pq.terms) == 0 { return nil }
+    res := make([]Result, 0)
+    for i, d := range ix.docs {
+        ok := true
+        for _, ph := range pq.phrases {
+            if !hasPhrase(d, ph) {
+                ok = false
+                break
+            }
+        }
+        if !ok { continue }
+        score := ix.score(d, pq.terms)
+        if score <= 0 { continue }
+        p := ix.pages[i]
+        res = append(res, Result{Title: p.Title, Snippet: makeSnippet(p.Content, pq.words, 160), Score: score})
+    }
+    return rank(res, limit)
+}
+
+type Result struct {
+    Title   string
+    Snippet string
+    Score   float64
+}
+
+// Search builds an index over db and runs query against it.
+func Search(db DB, query string, limit int) []Result {
+    return NewIndex(db).Search(query, limit)
+}
+
+// makeSnippet returns up to span bytes of content around the first
+// occurrence of any of words.
+func makeSnippet(content string, words []string, span int) string {
+    if span < 40 { span = 40 }
+    lc := strings.ToLower(content)
+    idx := -1
+    for _, w := range words {
+        if w == "" { continue }
+        if i := strings.Index(lc, w); i >= 0 && (idx < 0 || i < idx) { idx = i }
+    }
+    if idx < 0 {
+        if len(content) <= span { return content }
+        return content[:runeStart(content, span)] + "…"
+    }
+    start := idx - span/2
+    if start < 0 { start = 0 }
+    end := start + span
+    if end > len(content) { end = len(content) }
+    start = runeStart(content, start)
+    end = runeStart(content, end)
+    prefix := ""
+    suffix := ""
+    if start > 0 { prefix = "…" }
+    if end < len(content) { suffix = "…" }
+    return prefix + content[start:end] + suffix
+}
+
+// runeStart moves i back to the start of the UTF-8 sequence it falls in.
+func runeStart(s string, i int) int {
+    for i > 0 && i < len(s) && !utf8.RuneStart(s[i]) { i-- }
+    return i
+}
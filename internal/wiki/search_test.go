@@ -0,0 +1,71 @@
+package wiki
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestParseQuery(t *testing.T) {
+    tests := []struct {
+        q       string
+        terms   []string
+        phrases [][]string
+    }{
+        {"claude setup", []string{"claud", "setup"}, nil},
+        {"the claude and setup", []string{"claud", "setup"}, nil},
+        {`"claude setup"`, []string{"claud", "setup"}, [][]string{{"claud", "setup"}}},
+        // Stop-words inside a phrase keep their place; at its ends they are dropped.
+        {`"set up the claude cli"`, []string{"set", "up", "claud", "cli"}, [][]string{{"set", "up", "", "claud", "cli"}}},
+        {`"the setup of"`, []string{"setup"}, [][]string{{"setup"}}},
+        {`"the of" claude`, []string{"claud"}, nil},
+        {`"" claude`, []string{"claud"}, nil},
+        {`sandbox "exec trace" "sandbox"`, []string{"exec", "trac", "sandbox"}, [][]string{{"exec", "trac"}, {"sandbox"}}},
+        // An unbalanced quote is just a separator.
+        {`claude "setup`, []string{"claud", "setup"}, nil},
+    }
+    for _, tt := range tests {
+        pq := parseQuery(tt.q)
+        if !reflect.DeepEqual(pq.terms, tt.terms) { t.Errorf("parseQuery(%s).terms = %q, want %q", tt.q, pq.terms, tt.terms) }
+        if !reflect.DeepEqual(pq.phrases, tt.phrases) { t.Errorf("parseQuery(%s).phrases = %q, want %q", tt.q, pq.phrases, tt.phrases) }
+    }
+}
+
+func TestSearch(t *testing.T) {
+    db := DB{Pages: []Page{
+        {Title: "Claude", Tags: []string{"ai", "cli"}, Content: "Claude is an AI assistant. For the setup, run the installer and log in."},
+        {Title: "Setting up the Claude CLI", Content: "How to set up the Claude CLI on a new machine."},
+        {Title: "Gemini", Content: "Set up Claude? No, this page covers Gemini setup only."},
+        {Title: "Sandbox", Content: "The restricted profile sandboxes apps. Exec tracing records child processes."},
+        {Title: "Unrelated", Content: "Nothing to see here."},
+    }}
+    ix := NewIndex(db)
+    tests := []struct {
+        q    string
+        want []string // titles, best first; nil for no results
+    }{
+        // Free terms are OR'ed, so non-adjacent words still find the page.
+        {"claude setup", []string{"Claude", "Gemini", "Setting up the Claude CLI"}},
+        {"Claude Setup", []string{"Claude", "Gemini", "Setting up the Claude CLI"}},
+        // A phrase needs its words next to each other.
+        {`"claude setup"`, nil},
+        {`"gemini setup"`, []string{"Gemini"}},
+        // A stop-word in a phrase must line up with a stop-word in the page.
+        {`"set up the claude cli"`, []string{"Setting up the Claude CLI"}},
+        {`"set up a claude cli"`, []string{"Setting up the Claude CLI"}},
+        {`"set up claude"`, []string{"Gemini"}},
+        // Phrases and free terms combine; every phrase must match.
+        {`"exec tracing" sandbox`, []string{"Sandbox"}},
+        {`"exec tracing" "claude cli"`, nil},
+        // Stemming lets other word forms match.
+        {"sandboxing", []string{"Sandbox"}},
+        {"traces", []string{"Sandbox"}},
+        // Only stop-words: nothing to search for.
+        {"the and of", nil},
+        {"zebra", nil},
+    }
+    for _, tt := range tests {
+        var got []string
+        for _, r := range ix.Search(tt.q, 10) { got = append(got, r.Title) }
+        if !reflect.DeepEqual(got, tt.want) { t.Errorf("Search(%s) = %q, want %q", tt.q, got, tt.want) }
+    }
+}
@@ -0,0 +1,150 @@
+package wiki
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// VectorIndex is the persisted embedding index, stored next to wiki.json.
+type VectorIndex struct {
+    Embedder string        `json:"embedder"`
+    Entries  []VectorEntry `json:"entries"`
+}
+
+// VectorEntry holds one page's embedding. Hash detects stale entries.
+type VectorEntry struct {
+    Title  string    `json:"title"`
+    Hash   string    `json:"hash"`
+    Vector []float32 `json:"vector"`
+}
+
+// VectorsPath returns the index file for the wiki at wikiPath.
+func VectorsPath(wikiPath string) string {
+    return filepath.Join(filepath.Dir(wikiPath), strings.TrimSuffix(filepath.Base(wikiPath), ".json")+".vectors.json")
+}
+
+// LoadVectors reads a persisted index; a missing file yields an empty one.
+func LoadVectors(path string) (*VectorIndex, error) {
+    b, err := os.ReadFile(path)
+    if errors.Is(err, os.ErrNotExist) { return &VectorIndex{}, nil }
+    if err != nil { return nil, err }
+    var vi VectorIndex
+    if err := json.Unmarshal(b, &vi); err != nil { return nil, err }
+    return &vi, nil
+}
+
+// SaveVectors writes the index to path.
+func SaveVectors(path string, vi *VectorIndex) error {
+    b, err := json.Marshal(vi)
+    if err != nil { return err }
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { return err }
+    return os.WriteFile(path, b, 0o644)
+}
+
+func pageText(p Page) string {
+    return p.Title + "\n" + strings.Join(p.Aliases, " ") + "\n" + strings.Join(p.Tags, " ") + "\n" + p.Content
+}
+
+func pageHash(p Page) string {
+    sum := sha256.Sum256([]byte(pageText(p)))
+    return hex.EncodeToString(sum[:8])
+}
+
+// UpdateVectors brings prev up to date with db using emb, embedding only new
+// or changed pages. It reports whether anything changed.
+func UpdateVectors(db DB, emb Embedder, prev *VectorIndex) (*VectorIndex, bool, error) {
+    old := map[string]VectorEntry{}
+    if prev != nil && prev.Embedder == emb.Name() {
+        for _, e := range prev.Entries { old[e.Title+"\x00"+e.Hash] = e }
+    }
+    vi := &VectorIndex{Embedder: emb.Name()}
+    var todo []int
+    var texts []string
+    for _, p := range db.Pages {
+        h := pageHash(p)
+        if e, ok := old[p.Title+"\x00"+h]; ok {
+            vi.Entries = append(vi.Entries, e)
+            continue
+        }
+        todo = append(todo, len(vi.Entries))
+        texts = append(texts, pageText(p))
+        vi.Entries = append(vi.Entries, VectorEntry{Title: p.Title, Hash: h})
+    }
+    changed := len(todo) > 0 || prev == nil || len(prev.Entries) != len(vi.Entries) || prev.Embedder != vi.Embedder
+    if len(texts) > 0 {
+        vecs, err := emb.Embed(texts)
+        if err != nil { return nil, false, err }
+        for i, idx := range todo { vi.Entries[idx].Vector = vecs[i] }
+    }
+    return vi, changed, nil
+}
+
+// SemanticSearch ranks pages by cosine similarity between the query and
+// page embeddings in vi (which must be up to date with db).
+func SemanticSearch(db DB, vi *VectorIndex, emb Embedder, q string, limit int) ([]Result, error) {
+    scores, err := semanticScores(db, vi, emb, q)
+    if err != nil { return nil, err }
+    words := parseQuery(q).words
+    res := make([]Result, 0)
+    for i, p := range db.Pages {
+        if scores[i] <= 0 { continue }
+        res = append(res, Result{Title: p.Title, Snippet: makeSnippet(p.Content, words, 160), Score: scores[i]})
+    }
+    return rank(res, limit), nil
+}
+
+func semanticScores(db DB, vi *VectorIndex, emb Embedder, q string) ([]float64, error) {
+    qv, err := emb.Embed([]string{strings.ReplaceAll(q, `"`, " ")})
+    if err != nil { return nil, err }
+    byTitle := map[string][]float32{}
+    for _, e := range vi.Entries { byTitle[e.Title] = e.Vector }
+    scores := make([]float64, len(db.Pages))
+    for i, p := range db.Pages {
+        scores[i] = cosine(qv[0], byTitle[p.Title])
+    }
+    return scores, nil
+}
+
+// HybridSearch blends BM25 (normalized to the best hit) with cosine
+// similarity, weighting each half equally. Quoted phrases still filter.
+func HybridSearch(db DB, vi *VectorIndex, emb Embedder, q string, limit int) ([]Result, error) {
+    sem, err := semanticScores(db, vi, emb, q)
+    if err != nil { return nil, err }
+    ix := NewIndex(db)
+    pq := parseQuery(q)
+    lex := make([]float64, len(db.Pages))
+    maxLex := 0.0
+    for i, d := range ix.docs {
+        lex[i] = ix.score(d, pq.terms)
+        if lex[i] > maxLex { maxLex = lex[i] }
+    }
+    res := make([]Result, 0)
+    for i, p := range db.Pages {
+        skip := false
+        for _, ph := range pq.phrases {
+            if !hasPhrase(ix.docs[i], ph) { skip = true }
+        }
+        if skip { continue }
+        l := 0.0
+        if maxLex > 0 { l = lex[i] / maxLex }
+        score := 0.5*l + 0.5*sem[i]
+        if score <= 0 { continue }
+        res = append(res, Result{Title: p.Title, Snippet: makeSnippet(p.Content, pq.words, 160), Score: score})
+    }
+    return rank(res, limit), nil
+}
+
+func rank(res []Result, limit int) []Result {
+    sort.Slice(res, func(i, j int) bool {
+        if res[i].Score == res[j].Score { return res[i].Title < res[j].Title }
+        return res[i].Score > res[j].Score
+    })
+    if limit > 0 && len(res) > limit { return res[:limit] }
+    return res
+}
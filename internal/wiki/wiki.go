@@ -3,52 +3,90 @@ package wiki
 import (
     "encoding/json"
     "errors"
+    "fmt"
     "os"
     "path/filepath"
-    "sort"
     "strings"
 )
 
 type Page struct {
     Title   string   `json:"title"`
     Tags    []string `json:"tags,omitempty"`
+    Aliases []string `json:"aliases,omitempty"`
     Content string   `json:"content"`
+    // Source is the file the page was loaded from (not serialized).
+    Source string `json:"-"`
 }
 
 type DB struct {
     Pages []Page `json:"pages"`
 }
 
-// Locate looks for wiki.json starting from workdir.
+// DirName is the directory of Markdown pages that sits next to wiki.json.
+const DirName = "wiki"
+
+// Locate looks for wiki.json or a wiki/ directory starting from workdir and
+// returns the wiki.json path (which may not exist if only wiki/ does).
 func Locate(workdir string) (string, error) {
-    // Prefer repo local wiki.json
+    // Prefer repo local wiki.json / wiki/
     p := filepath.Join(workdir, "wiki.json")
-    if _, err := os.Stat(p); err == nil {
+    if exists(p) || isDir(filepath.Join(workdir, DirName)) {
         return p, nil
     }
     // Fallback: ~/.heimdall/wiki.json
     if h, err := os.UserHomeDir(); err == nil {
         hp := filepath.Join(h, ".heimdall", "wiki.json")
-        if _, err := os.Stat(hp); err == nil {
-            return hp, nil
-        }
-        return hp, nil // path to create
+        return hp, nil // may not exist yet: path to create
     }
     return p, nil
 }
 
+// Load reads wiki.json at path plus the Markdown pages in the wiki/ directory
+// next to it. Either may be missing, not both. Markdown pages replace JSON
+// pages with the same title.
 func Load(path string) (DB, error) {
+    var db DB
     b, err := os.ReadFile(path)
-    if err != nil {
+    switch {
+    case err == nil:
+        if err := json.Unmarshal(b, &db); err != nil {
+            return DB{}, fmt.Errorf("%s: %w", path, err)
+        }
+        for i := range db.Pages { db.Pages[i].Source = path }
+    case !errors.Is(err, os.ErrNotExist):
         return DB{}, err
     }
-    var db DB
-    if err := json.Unmarshal(b, &db); err != nil {
-        return DB{}, err
+    dir := filepath.Join(filepath.Dir(path), DirName)
+    if !isDir(dir) {
+        if err != nil { return DB{}, err } // neither wiki.json nor wiki/
+        return db, nil
+    }
+    mdPages, err := LoadDir(dir)
+    if err != nil { return DB{}, err }
+    for _, mp := range mdPages {
+        replaced := false
+        for i, p := range db.Pages {
+            if strings.EqualFold(p.Title, mp.Title) {
+                db.Pages[i] = mp
+                replaced = true
+                break
+            }
+        }
+        if !replaced { db.Pages = append(db.Pages, mp) }
     }
     return db, nil
 }
 
+func exists(path string) bool {
+    _, err := os.Stat(path)
+    return err == nil
+}
+
+func isDir(path string) bool {
+    fi, err := os.Stat(path)
+    return err == nil && fi.IsDir()
+}
+
 func Save(path string, db DB) error {
     if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { return err }
     b, err := json.MarshalIndent(db, "", "  ")
@@ -68,59 +106,15 @@ func Init(path string) error {
     return Save(path, sample)
 }
 
-type Result struct {
-    Title   string
-    Snippet string
-    Score   int
-}
-
-// Search performs a simple case-insensitive containment match over title, tags, content.
-func Search(db DB, query string, limit int) []Result {
-    q := strings.ToLower(strings.TrimSpace(query))
-    if q == "" { return nil }
-    res := make([]Result, 0)
-    for _, p := range db.Pages {
-        t := strings.ToLower(p.Title)
-        c := strings.ToLower(p.Content)
-        score := 0
-        if strings.Contains(t, q) { score += 5 }
-        for _, tg := range p.Tags { if strings.Contains(strings.ToLower(tg), q) { score += 2 } }
-        if strings.Contains(c, q) { score += 1 }
-        if score == 0 { continue }
-        snippet := makeSnippet(p.Content, q, 160)
-        res = append(res, Result{Title: p.Title, Snippet: snippet, Score: score})
-    }
-    sort.Slice(res, func(i, j int) bool {
-        if res[i].Score == res[j].Score { return res[i].Title < res[j].Title }
-        return res[i].Score > res[j].Score
-    })
-    if limit > 0 && len(res) > limit { return res[:limit] }
-    return res
-}
-
+// Show finds a page by title or alias, case-insensitively.
 func Show(db DB, title string) (Page, bool) {
     for _, p := range db.Pages {
         if strings.EqualFold(p.Title, title) { return p, true }
     }
-    return Page{}, false
-}
-
-func makeSnippet(content, q string, span int) string {
-    if span < 40 { span = 40 }
-    lc := strings.ToLower(content)
-    lq := strings.ToLower(q)
-    idx := strings.Index(lc, lq)
-    if idx < 0 {
-        if len(content) <= span { return content }
-        return content[:span] + "…"
+    for _, p := range db.Pages {
+        for _, a := range p.Aliases {
+            if strings.EqualFold(a, title) { return p, true }
+        }
     }
-    start := idx - span/2
-    if start < 0 { start = 0 }
-    end := start + span
-    if end > len(content) { end = len(content) }
-    prefix := ""
-    suffix := ""
-    if start > 0 { prefix = "…" }
-    if end < len(content) { suffix = "…" }
-    return prefix + content[start:end] + suffix
+    return Page{}, false
 }